@@ -17,21 +17,35 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"strconv"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/oklog/run"
+	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/rhobs/kube-events-exporter/internal/collector"
+	"github.com/rhobs/kube-events-exporter/internal/config"
 	"github.com/rhobs/kube-events-exporter/internal/exporter"
 	exporterhttp "github.com/rhobs/kube-events-exporter/internal/http"
 	"github.com/rhobs/kube-events-exporter/internal/options"
+	"github.com/rhobs/kube-events-exporter/internal/pushgateway"
+	"github.com/rhobs/kube-events-exporter/internal/remotewrite"
+	"github.com/rhobs/kube-events-exporter/internal/rules"
+	"github.com/rhobs/kube-events-exporter/internal/stream"
 	"github.com/rhobs/kube-events-exporter/internal/version"
+	"github.com/rhobs/kube-events-exporter/internal/web"
 
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/klog"
 )
@@ -50,55 +64,289 @@ func main() {
 		os.Exit(0)
 	}
 
-	kubeConfig, err := clientcmd.BuildConfigFromFlags(opts.Apiserver, opts.Kubeconfig)
+	cfg, err := config.Load(opts.ConfigFile)
 	if err != nil {
-		klog.Fatalf("failed to create cluster config from flags: %v", err)
+		klog.Fatalf("failed to load configuration: %v", err)
 	}
 
-	kubeClient, err := kubernetes.NewForConfig(kubeConfig)
+	exporterRegistry := prometheus.NewRegistry()
+	exporter.RegisterExporterCollectors(exporterRegistry)
+	exporter.RegisterClientMetrics(exporterRegistry)
+
+	clusters, err := newKubeClusters(opts)
 	if err != nil {
-		klog.Fatalf("failed to create cluster config: %v", err)
+		klog.Fatalf("failed to create cluster clients: %v", err)
+	}
+	if len(clusters) > 1 {
+		// Each cluster has its own sinks, which can't share a file.
+		for _, sinkCfg := range cfg.Sinks {
+			if sinkCfg.EventLog != nil && sinkCfg.EventLog.Path != "" {
+				klog.Fatalf("event log sink %s can't write to a file with several clusters", sinkCfg.Name)
+			}
+			if sinkCfg.Queue.DeadLetterPath != "" {
+				klog.Fatalf("sink %s can't write a dead letter log with several clusters", sinkCfg.Name)
+			}
+		}
 	}
 
-	exporterRegistry := prometheus.NewRegistry()
-	exporter.RegisterExporterCollectors(exporterRegistry)
+	ruleSet, err := rules.NewRuleSet(cfg.Rules, exporterRegistry)
+	if err != nil {
+		klog.Fatalf("failed to compile rules: %v", err)
+	}
+
+	// The Events of all the clusters are streamed together.
+	var eventsStream *stream.Broadcaster
+	if opts.EnableEventsStream {
+		eventsStream = stream.NewBroadcaster(opts.EventsStreamMaxSubscribers, opts.EventsStreamBufferSize, exporterRegistry)
+	}
 
 	eventRegistry := prometheus.NewRegistry()
-	eventCollector := collector.NewEventCollector(kubeClient, exporterRegistry, opts)
+	eventCollectors := make(collector.EventCollectors, 0, len(clusters))
+	for _, cluster := range clusters {
+		eventCollector, err := collector.NewEventCollector(cluster.client, cluster.name, exporterRegistry, opts, cfg, ruleSet, eventsStream)
+		if err != nil && cluster.name != "" {
+			err = errors.Wrapf(err, "cluster %s", cluster.name)
+		}
+		if err != nil {
+			klog.Fatalf("failed to create event collector: %v", err)
+		}
+		eventCollectors = append(eventCollectors, eventCollector)
+
+		clusterRegistry := collector.WrapRegisterer(eventRegistry, cluster.name)
+		clusterRegistry.MustRegister(eventCollector)
+		clusterRegistry.MustRegister(eventCollector.CustomMetrics()...)
+	}
 
 	stopCh := make(chan struct{})
-	defer close(stopCh)
+	eventCollectors.Run(stopCh)
 
-	eventCollector.Run(stopCh)
-	eventRegistry.MustRegister(eventCollector)
+	shutdown := &shutdown{timeout: opts.ShutdownTimeout}
+	defer shutdown.cancel()
+
+	var rg run.Group
+	rg.Add(handleSignals())
+
+	if eventsStream != nil {
+		// End the streams before shutting down the servers, which wait
+		// for the in-flight requests to complete. The group interrupts
+		// the actors in the order they were added.
+		streamStopCh := make(chan struct{})
+		rg.Add(func() error {
+			<-streamStopCh
+			return nil
+		}, func(error) {
+			eventsStream.Close()
+			close(streamStopCh)
+		})
+	}
 
 	eventMux := http.NewServeMux()
-	exporterhttp.RegisterEventsMuxHandlers(eventMux, eventRegistry, exporterRegistry)
-	exporterMux := http.NewServeMux()
-	exporterhttp.RegisterExporterMuxHandlers(exporterMux, exporterRegistry)
+	switch {
+	case opts.SinglePort && opts.MetricsPath == opts.ExporterMetricsPath:
+		// Serve all the metrics on the same path.
+		gatherers := prometheus.Gatherers{eventRegistry, exporterRegistry}
+		exporterhttp.RegisterEventsMuxHandlers(eventMux, opts.MetricsPath, gatherers, exporterRegistry)
+		exporterhttp.RegisterExporterMuxHandlers(eventMux, "", exporterRegistry, opts.EnableProfiling, eventCollectors.InformersStatus)
+	case opts.SinglePort:
+		exporterhttp.RegisterEventsMuxHandlers(eventMux, opts.MetricsPath, eventRegistry, exporterRegistry)
+		exporterhttp.RegisterExporterMuxHandlers(eventMux, opts.ExporterMetricsPath, exporterRegistry, opts.EnableProfiling, eventCollectors.InformersStatus)
+	default:
+		exporterhttp.RegisterEventsMuxHandlers(eventMux, opts.MetricsPath, eventRegistry, exporterRegistry)
+		exporterMux := http.NewServeMux()
+		exporterhttp.RegisterExporterMuxHandlers(exporterMux, opts.ExporterMetricsPath, exporterRegistry, opts.EnableProfiling, eventCollectors.InformersStatus)
+		rg.Add(listenAndServe(exporterMux, opts.ExporterHost, opts.ExporterPort, opts.WebConfigFile, shutdown))
+	}
+	exporterhttp.RegisterReadinessHandler(eventMux, eventCollectors.InformersStatus)
+	if opts.EnableEventsAPI {
+		exporterhttp.RegisterEventsAPIHandlers(eventMux, eventCollectors.ListEvents)
+	}
+	if eventsStream != nil {
+		exporterhttp.RegisterEventsStreamHandlers(eventMux, eventsStream)
+	}
+	rg.Add(listenAndServe(eventMux, opts.Host, opts.Port, opts.WebConfigFile, shutdown))
 
-	var rg run.Group
-	rg.Add(listenAndServe(exporterMux, opts.ExporterHost, opts.ExporterPort))
-	rg.Add(listenAndServe(eventMux, opts.Host, opts.Port))
-	klog.Fatalf("metrics servers terminated: %v", rg.Run())
+	if cfg.RemoteWrite != nil {
+		gatherers := prometheus.Gatherers{eventRegistry}
+		if cfg.RemoteWrite.ExporterMetrics {
+			gatherers = append(gatherers, exporterRegistry)
+		}
+		remoteWriter, err := remotewrite.New(cfg.RemoteWrite, gatherers, exporterRegistry)
+		if err != nil {
+			klog.Fatalf("failed to create remote writer: %v", err)
+		}
+
+		remoteWriteStopCh := make(chan struct{})
+		rg.Add(func() error {
+			remoteWriter.Run(remoteWriteStopCh)
+			return nil
+		}, func(error) {
+			close(remoteWriteStopCh)
+		})
+	}
+
+	if opts.PushGatewayURL != "" {
+		pusher := pushgateway.New(opts.PushGatewayURL, opts.PushGatewayJob, opts.PushGatewayGrouping, opts.PushGatewayInterval, eventRegistry, exporterRegistry)
+
+		// The pusher pushes a last time once interrupted, before the
+		// group returns.
+		pushStopCh := make(chan struct{})
+		rg.Add(func() error {
+			pusher.Run(pushStopCh)
+			return nil
+		}, func(error) {
+			close(pushStopCh)
+		})
+	}
+	err = rg.Run()
+	if err != nil {
+		klog.Errorf("metrics servers terminated: %v", err)
+	}
+
+	// Stop the informers and send the remaining Events to the sinks.
+	close(stopCh)
+	eventCollectors.Shutdown(shutdown.context())
+
+	if err != nil {
+		os.Exit(1)
+	}
+	klog.Info("shutdown complete")
 }
 
-func listenAndServe(mux *http.ServeMux, host string, port int) (func() error, func(error)) {
-	var listener net.Listener
-	serve := func() error {
-		addr := net.JoinHostPort(host, strconv.Itoa(port))
-		listener, err := net.Listen("tcp", addr)
+// kubeCluster is a cluster the Events are collected from.
+type kubeCluster struct {
+	name   string
+	client kubernetes.Interface
+}
+
+// newKubeClusters returns the clusters of the kubeconfig contexts, or the
+// cluster of the apiserver and kubeconfig flags, without name, if no context is
+// given.
+func newKubeClusters(opts *options.Options) ([]kubeCluster, error) {
+	if len(opts.KubeconfigContexts) == 0 {
+		kubeConfig, err := clientcmd.BuildConfigFromFlags(opts.Apiserver, opts.Kubeconfig)
 		if err != nil {
-			return err
+			return nil, errors.Wrap(err, "create cluster config from flags")
 		}
-		return http.Serve(listener, mux)
+		kubeClient, err := newKubeClient(kubeConfig, opts)
+		if err != nil {
+			return nil, err
+		}
+		return []kubeCluster{{client: kubeClient}}, nil
+	}
+
+	// Merge the kubeconfig files as kubectl does with KUBECONFIG.
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	if opts.Kubeconfig != "" {
+		loadingRules.Precedence = filepath.SplitList(opts.Kubeconfig)
+	}
+
+	clusters := make([]kubeCluster, 0, len(opts.KubeconfigContexts))
+	for _, kubeContext := range opts.KubeconfigContexts {
+		kubeConfig, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+			loadingRules,
+			&clientcmd.ConfigOverrides{CurrentContext: kubeContext},
+		).ClientConfig()
+		if err != nil {
+			return nil, errors.Wrapf(err, "create cluster config of context %s", kubeContext)
+		}
+		kubeClient, err := newKubeClient(kubeConfig, opts)
+		if err != nil {
+			return nil, errors.Wrapf(err, "context %s", kubeContext)
+		}
+		clusters = append(clusters, kubeCluster{name: kubeContext, client: kubeClient})
+	}
+	return clusters, nil
+}
+
+// newKubeClient returns a client of the cluster configured by kubeConfig,
+// applying the client options.
+func newKubeClient(kubeConfig *rest.Config, opts *options.Options) (kubernetes.Interface, error) {
+	kubeConfig.QPS = opts.KubeAPIQPS
+	kubeConfig.Burst = opts.KubeAPIBurst
+	kubeConfig.UserAgent = exporter.UserAgent()
+	kubeConfig.ContentType = opts.KubeAPIContentType
+	if opts.KubeAPIContentType == options.ContentTypeProtobuf {
+		// Fall back to JSON for the resources not supporting protobuf.
+		kubeConfig.AcceptContentTypes = options.ContentTypeProtobuf + "," + options.ContentTypeJSON
+	}
+	if opts.KubeAPITimeout > 0 {
+		// rest.Config.Timeout would also end the watch requests.
+		kubeConfig.Wrap(exporter.RequestTimeout(opts.KubeAPITimeout))
+	}
+
+	kubeClient, err := kubernetes.NewForConfig(kubeConfig)
+	return kubeClient, errors.Wrap(err, "create cluster client")
+}
+
+// shutdown bounds the graceful shutdown by a timeout starting when the
+// shutdown is initiated.
+type shutdown struct {
+	once    sync.Once
+	timeout time.Duration
+	ctx     context.Context
+	cancelF context.CancelFunc
+}
+
+// context returns the context of the shutdown, starting its timeout on the
+// first call.
+func (s *shutdown) context() context.Context {
+	s.once.Do(func() {
+		s.ctx, s.cancelF = context.WithTimeout(context.Background(), s.timeout)
+	})
+	return s.ctx
+}
+
+func (s *shutdown) cancel() {
+	if s.cancelF != nil {
+		s.cancelF()
+	}
+}
+
+// handleSignals returns once SIGTERM or SIGINT is received.
+func handleSignals() (func() error, func(error)) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+	cancel := make(chan struct{})
+	execute := func() error {
+		select {
+		case sig := <-signals:
+			klog.Infof("received %s, shutting down", sig)
+		case <-cancel:
+		}
+		return nil
+	}
+	interrupt := func(error) {
+		signal.Stop(signals)
+		close(cancel)
+	}
+	return execute, interrupt
+}
+
+func listenAndServe(mux *http.ServeMux, host string, port int, webConfigFile string, shutdown *shutdown) (func() error, func(error)) {
+	server := &http.Server{
+		Addr:    net.JoinHostPort(host, strconv.Itoa(port)),
+		Handler: mux,
+	}
+	webServer, err := web.NewServer(server, webConfigFile)
+	if err != nil {
+		klog.Fatalf("failed to configure server %s: %v", server.Addr, err)
+	}
+	reloadStopCh := make(chan struct{})
+	serve := func() error {
+		go webServer.Run(reloadStopCh)
+		err := webServer.ListenAndServe()
+		if err == http.ErrServerClosed {
+			return nil
+		}
+		return err
 	}
 	cleanup := func(error) {
-		if listener != nil {
-			err := listener.Close()
-			if err != nil {
-				klog.Errorf("failed to close listener: %v", err)
-			}
+		close(reloadStopCh)
+		// Stop accepting connections and wait for the in-flight
+		// requests to complete.
+		err := server.Shutdown(shutdown.context())
+		if err != nil {
+			klog.Errorf("failed to shut down server %s: %v", server.Addr, err)
 		}
 	}
 	return serve, cleanup
@@ -4,6 +4,8 @@ go 1.14
 
 require (
 	github.com/ghodss/yaml v1.0.0
+	github.com/golang/snappy v0.0.4
+	github.com/google/cel-go v0.12.6
 	github.com/imdario/mergo v0.3.9 // indirect
 	github.com/oklog/run v1.1.0
 	github.com/pkg/errors v0.8.1
@@ -11,8 +13,13 @@ require (
 	github.com/prometheus/client_model v0.2.0
 	github.com/prometheus/common v0.9.1
 	github.com/spf13/pflag v1.0.5
-	golang.org/x/oauth2 v0.0.0-20200107190931-bf48bf16ab8d // indirect
+	go.opentelemetry.io/proto/otlp v0.19.0
+	golang.org/x/crypto v0.0.0-20200622213623-75b288015ac9
 	golang.org/x/time v0.0.0-20200416051211-89c76fbcd5d1 // indirect
+	google.golang.org/genproto v0.0.0-20220502173005-c8bf987b8c21
+	google.golang.org/grpc v1.46.0
+	google.golang.org/protobuf v1.28.0
+	gopkg.in/natefinch/lumberjack.v2 v2.0.0
 	k8s.io/api v0.18.2
 	k8s.io/apimachinery v0.18.2
 	k8s.io/client-go v0.18.2
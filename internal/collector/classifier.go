@@ -0,0 +1,73 @@
+/*
+Copyright 2020 Red Hat, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"regexp"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rhobs/kube-events-exporter/internal/config"
+)
+
+// messageCategoryOther is the category of the messages not matching any
+// classifier.
+const messageCategoryOther = "other"
+
+type messageClassifier struct {
+	name         string
+	regex        *regexp.Regexp
+	category     string
+	matchesTotal prometheus.Counter
+}
+
+type messageClassifiers []messageClassifier
+
+func newMessageClassifiers(cfgs []config.MessageClassifierConfig, exporterRegistry prometheus.Registerer) (messageClassifiers, error) {
+	matchesTotal := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kube_events_exporter_message_classifier_matches_total",
+		Help: "Number of Event messages matched by a classifier.",
+	}, []string{"classifier"})
+	exporterRegistry.MustRegister(matchesTotal)
+
+	classifiers := make(messageClassifiers, 0, len(cfgs))
+	for _, cfg := range cfgs {
+		regex, err := regexp.Compile(cfg.Regex)
+		if err != nil {
+			return nil, errors.Wrapf(err, "compile message classifier %q", cfg.Name)
+		}
+		classifiers = append(classifiers, messageClassifier{
+			name:         cfg.Name,
+			regex:        regex,
+			category:     cfg.Category,
+			matchesTotal: matchesTotal.WithLabelValues(cfg.Name),
+		})
+	}
+
+	return classifiers, nil
+}
+
+// classify returns the category of the first classifier matching the message.
+func (c messageClassifiers) classify(message string) string {
+	for _, classifier := range c {
+		if classifier.regex.MatchString(message) {
+			classifier.matchesTotal.Inc()
+			return classifier.category
+		}
+	}
+	return messageCategoryOther
+}
@@ -0,0 +1,155 @@
+/*
+Copyright 2020 Red Hat, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rhobs/kube-events-exporter/internal/config"
+	"github.com/rhobs/kube-events-exporter/internal/rules"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestMessageClassifiers(t *testing.T) {
+	classifiers, err := newMessageClassifiers([]config.MessageClassifierConfig{
+		{Name: "secret", Regex: `secret ".*" not found`, Category: "secret_not_found"},
+		{Name: "timeout", Regex: `timed out|timeout`, Category: "timeout"},
+	}, prometheus.NewRegistry())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		desc     string
+		message  string
+		expected string
+	}{
+		{
+			desc:     "Secret",
+			message:  `MountVolume.SetUp failed for volume "creds" : secret "creds" not found`,
+			expected: "secret_not_found",
+		},
+		{
+			desc:     "Timeout",
+			message:  "Unable to attach or mount volumes: timed out waiting for the condition",
+			expected: "timeout",
+		},
+		{
+			desc:     "Other",
+			message:  "Successfully assigned default/nginx to node",
+			expected: messageCategoryOther,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			got := classifiers.classify(tc.message)
+			if got != tc.expected {
+				t.Fatalf("expected %s category, got %s", tc.expected, got)
+			}
+		})
+	}
+
+	for _, classifier := range classifiers {
+		if testutil.ToFloat64(classifier.matchesTotal) != 1 {
+			t.Fatalf("expected classifier %s to match once", classifier.name)
+		}
+	}
+}
+
+func TestEventsTotalSinkClassifiesCountedEvents(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	ruleSet, err := rules.NewRuleSet([]config.RuleConfig{
+		{Name: "warnings", Expression: `type == "Warning"`},
+	}, registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	classifiers, err := newMessageClassifiers([]config.MessageClassifierConfig{
+		{Name: "timeout", Regex: `timed out`, Category: "timeout"},
+	}, registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &eventsTotalSink{
+		rule:        ruleSet.Get("warnings"),
+		classifiers: classifiers,
+		metrics:     newExporterMetrics(registry, true, nil),
+	}
+
+	// Only the Event counted by kube_events_total is classified.
+	message := "Unable to attach or mount volumes: timed out waiting for the condition"
+	for _, evType := range []string{v1.EventTypeNormal, v1.EventTypeWarning} {
+		err := s.OnEvent(context.Background(), &v1.Event{Type: evType, Message: message}, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := testutil.ToFloat64(classifiers[0].matchesTotal); got != 1 {
+		t.Fatalf("expected classifier to match once, got %v", got)
+	}
+}
+
+func TestEventsTotalSinkUnchangedCount(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	classifiers, err := newMessageClassifiers([]config.MessageClassifierConfig{
+		{Name: "timeout", Regex: `timed out`, Category: "timeout"},
+	}, registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	metrics := newExporterMetrics(registry, true, nil)
+	start := time.Now()
+	collector := newTestEventCollector(start, &eventsTotalSink{
+		classifiers: classifiers,
+		metrics:     metrics,
+	})
+
+	// The update doesn't raise the count of the Event, so it is neither
+	// classified nor counted again.
+	ev := newTestEvent("event", "FailedMount", start.Add(time.Minute))
+	ev.FirstTimestamp = metav1.NewTime(start.Add(time.Minute))
+	ev.Message = "Unable to attach or mount volumes: timed out waiting for the condition"
+	ev.Count = 1
+	updated := ev.DeepCopy()
+	updated.ResourceVersion = "2"
+	collector.handleEvent(ev)
+	collector.handleEvent(updated)
+
+	if got := testutil.ToFloat64(classifiers[0].matchesTotal); got != 1 {
+		t.Fatalf("expected classifier to match once, got %v", got)
+	}
+	if got := testutil.CollectAndCount(metrics.eventsTotal); got != 1 {
+		t.Fatalf("expected 1 kube_events_total series, got %d", got)
+	}
+
+	// Sinks ignore Events without new occurrences as well.
+	err = collector.sinks[0].OnEvent(context.Background(), updated, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := testutil.ToFloat64(classifiers[0].matchesTotal); got != 1 {
+		t.Fatalf("expected classifier to match once, got %v", got)
+	}
+}
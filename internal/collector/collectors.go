@@ -0,0 +1,97 @@
+/*
+Copyright 2020 Red Hat, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rhobs/kube-events-exporter/internal/config"
+	"github.com/rhobs/kube-events-exporter/pkg/informer"
+)
+
+// ClusterLabel is the label naming the cluster of the metrics when Events are
+// collected from several clusters.
+const ClusterLabel = config.ClusterLabel
+
+// WrapRegisterer returns a prometheus.Registerer adding the cluster label to
+// the metrics registered in registerer, or registerer itself if cluster is
+// empty.
+func WrapRegisterer(registerer prometheus.Registerer, cluster string) prometheus.Registerer {
+	if cluster == "" {
+		return registerer
+	}
+	return prometheus.WrapRegistererWith(prometheus.Labels{ClusterLabel: cluster}, registerer)
+}
+
+// EventCollectors are the EventCollectors of the clusters the Events are
+// collected from. Each EventCollector runs its own informers and sinks, so that
+// a failing cluster doesn't affect the others.
+type EventCollectors []*EventCollector
+
+// Run runs all the EventCollectors.
+func (collectors EventCollectors) Run(stopCh <-chan struct{}) {
+	for _, collector := range collectors {
+		collector.Run(stopCh)
+	}
+}
+
+// Shutdown shuts down all the EventCollectors concurrently, see
+// EventCollector.Shutdown.
+func (collectors EventCollectors) Shutdown(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, collector := range collectors {
+		wg.Add(1)
+		go func(collector *EventCollector) {
+			defer wg.Done()
+			collector.Shutdown(ctx)
+		}(collector)
+	}
+	wg.Wait()
+}
+
+// InformersStatus returns the status of the Event informers of all the
+// clusters.
+func (collectors EventCollectors) InformersStatus() []informer.Status {
+	var statuses []informer.Status
+	for _, collector := range collectors {
+		statuses = append(statuses, collector.InformersStatus()...)
+	}
+	return statuses
+}
+
+// ListEvents returns the cached Events of all the clusters matching the
+// selector which were last seen at or after since, the most recent first.
+func (collectors EventCollectors) ListEvents(selector informer.Selector, since time.Time) ([]informer.ClusterEvent, error) {
+	if len(collectors) == 1 {
+		return collectors[0].ListEvents(selector, since)
+	}
+
+	var events []informer.ClusterEvent
+	for _, collector := range collectors {
+		clusterEvents, err := collector.ListEvents(selector, since)
+		if err != nil {
+			return nil, errors.Wrapf(err, "list events of cluster %s", collector.Cluster())
+		}
+		events = append(events, clusterEvents...)
+	}
+	sortEvents(events)
+	return events, nil
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2020 Red Hat, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rhobs/kube-events-exporter/pkg/informer"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestEventCollectorsListEvents(t *testing.T) {
+	now := time.Now()
+	var collectors EventCollectors
+	for i, cluster := range []string{"a", "b"} {
+		ev := newTestEvent("event", "BackOff", now.Add(time.Duration(-i)*time.Minute))
+
+		inf := cache.NewSharedIndexInformer(nil, &v1.Event{}, 0, informer.EventIndexers())
+		err := inf.GetIndexer().Add(ev)
+		if err != nil {
+			t.Fatal(err)
+		}
+		collectors = append(collectors, &EventCollector{
+			cluster:   cluster,
+			informers: []eventInformer{{SharedIndexInformer: inf}},
+		})
+	}
+
+	// The Events of both clusters have the same UID and are both listed.
+	events, err := collectors.ListEvents(informer.Selector{}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var clusters []string
+	for _, ev := range events {
+		clusters = append(clusters, ev.Cluster)
+	}
+	if expected := []string{"a", "b"}; !reflect.DeepEqual(clusters, expected) {
+		t.Fatalf("expected events of clusters %v, got %v", expected, clusters)
+	}
+
+	statuses := collectors.InformersStatus()
+	if len(statuses) != 2 || statuses[0].Cluster != "a" || statuses[1].Cluster != "b" {
+		t.Fatalf("unexpected informers status %+v", statuses)
+	}
+}
+
+func TestWrapRegisterer(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	for _, cluster := range []string{"a", "b"} {
+		counter := prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "test_total",
+			Help: "Test counter",
+		})
+		WrapRegisterer(registry, cluster).MustRegister(counter)
+		counter.Inc()
+	}
+
+	expected := `
+# HELP test_total Test counter
+# TYPE test_total counter
+test_total{cluster="a"} 1
+test_total{cluster="b"} 1
+`
+	err := testutil.GatherAndCompare(registry, strings.NewReader(expected), "test_total")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if WrapRegisterer(registry, "") != prometheus.Registerer(registry) {
+		t.Fatal("expected registerer to be unwrapped without cluster")
+	}
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2020 Red Hat, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// deletedEventRetention is how long the count of a deleted Event is kept, in
+// case the Event is added back to the informer caches by a relist.
+const deletedEventRetention = 10 * time.Minute
+
+// eventCounts keeps the count of the Events last seen by the informers by UID,
+// so that their new occurrences are computed against it rather than against
+// the cached Events, which are lost when an Event is deleted from the caches
+// and added back by a relist. It isn't safe for concurrent use.
+type eventCounts struct {
+	counts    map[types.UID]*eventCount
+	lastPrune time.Time
+}
+
+type eventCount struct {
+	count int32
+	// deleted is when the Event was deleted from the informer caches, or
+	// zero if it wasn't.
+	deleted time.Time
+}
+
+func newEventCounts() *eventCounts {
+	return &eventCounts{
+		counts: map[types.UID]*eventCount{},
+	}
+}
+
+// update records the count of an Event and returns its number of new
+// occurrences since it was last seen, or false if it wasn't seen before.
+func (c *eventCounts) update(ev *v1.Event) (int32, bool) {
+	count := getEventCount(ev)
+	last, ok := c.counts[ev.UID]
+	if !ok {
+		c.counts[ev.UID] = &eventCount{count: count}
+		return 0, false
+	}
+
+	last.deleted = time.Time{}
+	// A relist served from the watch cache of the apiserver can return an
+	// older version of the Event, whose occurrences were already counted.
+	if count <= last.count {
+		return 0, true
+	}
+	nbNew := count - last.count
+	last.count = count
+	return nbNew, true
+}
+
+// delete marks an Event as deleted and forgets the Events deleted for more
+// than deletedEventRetention.
+func (c *eventCounts) delete(uid types.UID, now time.Time) {
+	if last, ok := c.counts[uid]; ok {
+		last.deleted = now
+	}
+
+	if now.Sub(c.lastPrune) < deletedEventRetention {
+		return
+	}
+	c.lastPrune = now
+	for uid, last := range c.counts {
+		if !last.deleted.IsZero() && now.Sub(last.deleted) > deletedEventRetention {
+			delete(c.counts, uid)
+		}
+	}
+}
+
+// getEventCount returns the number of occurrences of an Event.
+func getEventCount(ev *v1.Event) int32 {
+	return updatedEventNb(&v1.Event{}, ev)
+}
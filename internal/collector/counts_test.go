@@ -0,0 +1,45 @@
+/*
+Copyright 2020 Red Hat, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestEventCountsDelete(t *testing.T) {
+	now := time.Now()
+	counts := newEventCounts()
+	for _, uid := range []types.UID{"deleted", "recent", "cached"} {
+		counts.update(&v1.Event{ObjectMeta: metav1.ObjectMeta{UID: uid}, Count: 1})
+	}
+
+	counts.delete("deleted", now)
+	counts.delete("recent", now.Add(deletedEventRetention))
+	counts.delete("unknown", now.Add(2*deletedEventRetention))
+
+	// The Events deleted for more than deletedEventRetention are forgotten.
+	for uid, expected := range map[types.UID]bool{"deleted": false, "recent": true, "cached": true} {
+		if _, ok := counts.counts[uid]; ok != expected {
+			t.Errorf("expected count of %s to be kept: %t", uid, expected)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+/*
+Copyright 2020 Red Hat, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/rhobs/kube-events-exporter/internal/config"
+	"github.com/rhobs/kube-events-exporter/internal/rules"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog"
+)
+
+// CustomMetricCollector is a prometheus.Collector exposing a user-defined
+// metric derived from Kubernetes Events.
+type CustomMetricCollector struct {
+	name   string
+	rule   *rules.Rule
+	labels []labelExtractor
+	value  valueExtractor
+
+	vec    prometheus.Collector
+	record func(labels []string, value float64)
+}
+
+type labelExtractor struct {
+	field string
+	regex *regexp.Regexp
+}
+
+type valueExtractor struct {
+	regex *regexp.Regexp
+}
+
+// NewCustomMetricCollector returns a prometheus.Collector observing the Events
+// matching the given metric configuration.
+func NewCustomMetricCollector(cfg config.MetricConfig, ruleSet *rules.RuleSet) (*CustomMetricCollector, error) {
+	if !model.IsValidMetricName(model.LabelValue(cfg.Name)) {
+		return nil, errors.Errorf("invalid metric name %q", cfg.Name)
+	}
+
+	c := &CustomMetricCollector{
+		name: cfg.Name,
+		rule: ruleSet.Get(cfg.Rule),
+	}
+
+	labelNames := make([]string, 0, len(cfg.Labels))
+	for _, label := range cfg.Labels {
+		if !model.LabelName(label.Name).IsValid() {
+			return nil, errors.Errorf("invalid label name %q", label.Name)
+		}
+		labelNames = append(labelNames, label.Name)
+
+		extractor := labelExtractor{field: label.Field}
+		if label.Field != "" {
+			// Resolve the field on an empty Event to catch invalid paths
+			// at startup.
+			_, err := rules.EventField(&v1.Event{}, label.Field)
+			if err != nil {
+				return nil, errors.Wrapf(err, "label %q", label.Name)
+			}
+		} else {
+			regex, err := compileCaptureRegex(label.MessageRegex)
+			if err != nil {
+				return nil, errors.Wrapf(err, "label %q", label.Name)
+			}
+			extractor.regex = regex
+		}
+		c.labels = append(c.labels, extractor)
+	}
+
+	if cfg.Value.MessageRegex != "" {
+		regex, err := compileCaptureRegex(cfg.Value.MessageRegex)
+		if err != nil {
+			return nil, errors.Wrap(err, "value")
+		}
+		c.value.regex = regex
+	}
+
+	switch cfg.Type {
+	case config.MetricTypeGauge:
+		vec := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: cfg.Name,
+			Help: cfg.Help,
+		}, labelNames)
+		c.vec = vec
+		c.record = func(labels []string, value float64) {
+			vec.WithLabelValues(labels...).Set(value)
+		}
+	case config.MetricTypeHistogram:
+		buckets := cfg.Buckets
+		if len(buckets) == 0 {
+			buckets = prometheus.DefBuckets
+		}
+		vec := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    cfg.Name,
+			Help:    cfg.Help,
+			Buckets: buckets,
+		}, labelNames)
+		c.vec = vec
+		c.record = func(labels []string, value float64) {
+			vec.WithLabelValues(labels...).Observe(value)
+		}
+	default:
+		vec := prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: cfg.Name,
+			Help: cfg.Help,
+		}, labelNames)
+		c.vec = vec
+		c.record = func(labels []string, value float64) {
+			if value < 0 {
+				klog.V(4).Infof("ignoring negative value %f for counter %s", value, cfg.Name)
+				return
+			}
+			vec.WithLabelValues(labels...).Add(value)
+		}
+	}
+
+	return c, nil
+}
+
+// Describe implements the prometheus.Collector interface.
+func (c *CustomMetricCollector) Describe(ch chan<- *prometheus.Desc) {
+	c.vec.Describe(ch)
+}
+
+// Collect implements the prometheus.Collector interface.
+func (c *CustomMetricCollector) Collect(ch chan<- prometheus.Metric) {
+	c.vec.Collect(ch)
+}
+
+// OnEvent implements the sink.Sink interface. Events not matching the metric
+// rule or the label and value regular expressions are ignored.
+func (c *CustomMetricCollector) OnEvent(_ context.Context, ev *v1.Event, nbNew float64) error {
+	if nbNew <= 0 {
+		return nil
+	}
+
+	if c.rule != nil && !c.rule.Match(ev) {
+		return nil
+	}
+
+	labels := make([]string, 0, len(c.labels))
+	for _, extractor := range c.labels {
+		if extractor.regex != nil {
+			match := extractor.regex.FindStringSubmatch(ev.Message)
+			if match == nil {
+				return nil
+			}
+			labels = append(labels, match[1])
+			continue
+		}
+
+		value, err := rules.EventField(ev, extractor.field)
+		if err != nil {
+			klog.V(4).Infof("failed to extract label for metric %s: %v", c.name, err)
+			return nil
+		}
+		labels = append(labels, value)
+	}
+
+	value := nbNew
+	if c.value.regex != nil {
+		match := c.value.regex.FindStringSubmatch(ev.Message)
+		if match == nil {
+			return nil
+		}
+		parsed, err := strconv.ParseFloat(match[1], 64)
+		if err != nil {
+			klog.V(4).Infof("failed to parse value for metric %s: %v", c.name, err)
+			return nil
+		}
+		value = parsed
+	}
+
+	c.record(labels, value)
+	return nil
+}
+
+func compileCaptureRegex(expr string) (*regexp.Regexp, error) {
+	regex, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, errors.Wrapf(err, "compile regex %q", expr)
+	}
+	if regex.NumSubexp() < 1 {
+		return nil, errors.Errorf("regex %q has no capture group", expr)
+	}
+	return regex, nil
+}
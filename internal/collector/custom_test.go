@@ -0,0 +1,180 @@
+/*
+Copyright 2020 Red Hat, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rhobs/kube-events-exporter/internal/config"
+	"github.com/rhobs/kube-events-exporter/internal/rules"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestCustomMetricCollector(t *testing.T) {
+	ruleSet, err := rules.NewRuleSet([]config.RuleConfig{
+		{Name: "failed", Expression: `reason == "Failed"`},
+	}, prometheus.NewRegistry())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events := []struct {
+		event *v1.Event
+		nbNew float64
+	}{
+		{
+			event: &v1.Event{
+				Reason:         "Failed",
+				Message:        `Failed to pull image "nginx:latest": rpc error: took 1.5s`,
+				InvolvedObject: v1.ObjectReference{Namespace: "default"},
+			},
+			nbNew: 2,
+		},
+		{
+			event: &v1.Event{
+				Reason:         "Failed",
+				Message:        "Error: ImagePullBackOff",
+				InvolvedObject: v1.ObjectReference{Namespace: "default"},
+			},
+			nbNew: 1,
+		},
+		{
+			event: &v1.Event{
+				Reason:         "Pulled",
+				Message:        `Successfully pulled image "nginx:latest"`,
+				InvolvedObject: v1.ObjectReference{Namespace: "default"},
+			},
+			nbNew: 1,
+		},
+	}
+
+	testCases := []struct {
+		desc     string
+		cfg      config.MetricConfig
+		expected string
+	}{
+		{
+			desc: "CounterMessageLabel",
+			cfg: config.MetricConfig{
+				Name: "image_pull_failures_total",
+				Help: "Image pull failures.",
+				Type: config.MetricTypeCounter,
+				Rule: "failed",
+				Labels: []config.LabelConfig{
+					{Name: "namespace", Field: "involvedObject.namespace"},
+					{Name: "image", MessageRegex: `image "([^"]+)"`},
+				},
+			},
+			expected: `
+# HELP image_pull_failures_total Image pull failures.
+# TYPE image_pull_failures_total counter
+image_pull_failures_total{image="nginx:latest",namespace="default"} 2
+`,
+		},
+		{
+			desc: "GaugeMessageValue",
+			cfg: config.MetricConfig{
+				Name:  "image_pull_duration_seconds",
+				Help:  "Duration of the last image pull.",
+				Type:  config.MetricTypeGauge,
+				Value: config.ValueConfig{MessageRegex: `took ([0-9.]+)s`},
+			},
+			expected: `
+# HELP image_pull_duration_seconds Duration of the last image pull.
+# TYPE image_pull_duration_seconds gauge
+image_pull_duration_seconds 1.5
+`,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+			c, err := NewCustomMetricCollector(tc.cfg, ruleSet)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, ev := range events {
+				err = c.OnEvent(context.Background(), ev.event, ev.nbNew)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err = testutil.CollectAndCompare(c, strings.NewReader(tc.expected))
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestNewCustomMetricCollectorInvalid(t *testing.T) {
+	testCases := []struct {
+		desc string
+		cfg  config.MetricConfig
+	}{
+		{
+			desc: "MetricName",
+			cfg:  config.MetricConfig{Name: "invalid-name"},
+		},
+		{
+			desc: "LabelName",
+			cfg: config.MetricConfig{
+				Name:   "valid_name",
+				Labels: []config.LabelConfig{{Name: "invalid-name", Field: "reason"}},
+			},
+		},
+		{
+			desc: "LabelField",
+			cfg: config.MetricConfig{
+				Name:   "valid_name",
+				Labels: []config.LabelConfig{{Name: "kind", Field: "involvedObject.unknown"}},
+			},
+		},
+		{
+			desc: "NoCaptureGroup",
+			cfg: config.MetricConfig{
+				Name:   "valid_name",
+				Labels: []config.LabelConfig{{Name: "image", MessageRegex: "image"}},
+			},
+		},
+	}
+
+	ruleSet, err := rules.NewRuleSet(nil, prometheus.NewRegistry())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+			_, err := NewCustomMetricCollector(tc.cfg, ruleSet)
+			if err == nil {
+				t.Fatal("expected metric creation to fail")
+			}
+		})
+	}
+}
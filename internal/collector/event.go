@@ -17,51 +17,155 @@ limitations under the License.
 package collector
 
 import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rhobs/kube-events-exporter/internal/config"
 	"github.com/rhobs/kube-events-exporter/internal/options"
+	"github.com/rhobs/kube-events-exporter/internal/relabel"
+	"github.com/rhobs/kube-events-exporter/internal/rules"
+	"github.com/rhobs/kube-events-exporter/internal/sink"
+	"github.com/rhobs/kube-events-exporter/internal/stream"
 	"github.com/rhobs/kube-events-exporter/pkg/informer"
 
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog"
 )
 
 // EventCollector is a prometeus.Collector that bundles all the metrics related
 // to Kubernetes Events.
 type EventCollector struct {
-	kclient   kubernetes.Interface
-	metrics   *exporterMetrics
-	lock      sync.Mutex
-	filter    eventFilter
-	informers []cache.SharedIndexInformer
+	kclient       kubernetes.Interface
+	cluster       string
+	metrics       *exporterMetrics
+	lock          sync.Mutex
+	filter        eventFilter
+	transform     informer.EventTransform
+	listOptions   informer.ListOptions
+	customMetrics []*CustomMetricCollector
+	queues        []*configuredSink
+	counts        *eventCounts
+	sinks         []sink.Sink
+	informers     []eventInformer
+	informersWG   sync.WaitGroup
+
+	// ctx is passed to the sinks and cancelled once the channel passed to
+	// Run is closed, so that a sink blocking on a full queue doesn't hold
+	// the lock forever.
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
 // NewEventCollector returns a prometheus.Collector collecting metrics about
-// Kubernetes Events.
-func NewEventCollector(kclient kubernetes.Interface, exporterRegistry *prometheus.Registry, opts *options.Options) *EventCollector {
+// Kubernetes Events. If cluster isn't empty, it names the cluster of the Events
+// and the metrics registered in exporterRegistry have a cluster label, see
+// WrapRegisterer. The accepted Events are pushed to eventsStream, shared by
+// the collectors of all the clusters, unless it is nil.
+func NewEventCollector(kclient kubernetes.Interface, cluster string, exporterRegistry prometheus.Registerer, opts *options.Options, cfg *config.Config, ruleSet *rules.RuleSet, eventsStream *stream.Broadcaster) (*EventCollector, error) {
+	exporterRegistry = WrapRegisterer(exporterRegistry, cluster)
 	collector := &EventCollector{
 		kclient: kclient,
+		cluster: cluster,
 		lock:    sync.Mutex{},
+		counts:  newEventCounts(),
 		filter: eventFilter{
 			creationTimestamp: time.Now(),
 			apiGroups:         opts.InvolvedObjectAPIGroups,
 			controllers:       opts.ReportingControllers,
 		},
-		metrics: newExporterMetrics(exporterRegistry),
+		listOptions: informer.ListOptions{
+			PageSize: opts.ListPageSize,
+			Timeout:  opts.KubeAPITimeout,
+		},
+		transform: informer.EventTransform{
+			DropUnusedFields: !cfg.Cache.KeepUnusedFields,
+			DropAnnotations:  cfg.Cache.DropAnnotations,
+			DropLabels:       cfg.Cache.DropLabels,
+			MaxMessageLength: cfg.Cache.MaxMessageLength,
+		},
+	}
+	collector.ctx, collector.cancel = context.WithCancel(context.Background())
+
+	relabelRules, err := relabel.New(cfg.EventsTotal.RelabelConfigs)
+	if err != nil {
+		return nil, errors.Wrap(err, "create eventsTotal relabel rules")
+	}
+	collector.metrics = newExporterMetrics(exporterRegistry, cfg.EventsTotal.MessageCategory, relabelRules)
+
+	classifiers, err := newMessageClassifiers(cfg.MessageClassifiers, exporterRegistry)
+	if err != nil {
+		return nil, err
+	}
+	collector.sinks = append(collector.sinks, &eventsTotalSink{
+		rule:        ruleSet.Get(cfg.EventsTotal.Rule),
+		classifiers: classifiers,
+		metrics:     collector.metrics,
+	})
+
+	for _, metricCfg := range cfg.Metrics {
+		customMetric, err := NewCustomMetricCollector(metricCfg, ruleSet)
+		if err != nil {
+			return nil, errors.Wrapf(err, "create metric %s", metricCfg.Name)
+		}
+		collector.customMetrics = append(collector.customMetrics, customMetric)
+		collector.sinks = append(collector.sinks, customMetric)
 	}
 
+	// Sinks exporting metrics share the kube_events_total series exposed to
+	// Prometheus.
+	eventsTotalRegistry := prometheus.NewRegistry()
+	WrapRegisterer(eventsTotalRegistry, cluster).MustRegister(collector.metrics.eventsTotal)
+	collector.queues, err = newConfiguredSinks(cfg.Sinks, cluster, ruleSet, exporterRegistry, eventsTotalRegistry)
+	if err != nil {
+		return nil, err
+	}
+	for _, queue := range collector.queues {
+		collector.sinks = append(collector.sinks, queue)
+	}
+
+	if eventsStream != nil {
+		collector.sinks = append(collector.sinks, eventsStream.ClusterSink(cluster))
+	}
+
+	cachedEventsHandler := informer.NewCachedEventsHandler(collector.metrics.cachedEvents)
 	for _, ns := range opts.InvolvedObjectNamespaces {
 		for _, evType := range opts.EventTypes {
 			inf := collector.newEventInformer(ns, evType)
 			inf.AddEventHandler(collector.eventHandler())
+			inf.AddEventHandler(informer.NewCacheSizeHandler(collector.metrics.cacheBytes))
+			inf.AddEventHandler(cachedEventsHandler)
 			collector.informers = append(collector.informers, inf)
 		}
 	}
-	return collector
+
+	exporterRegistry.MustRegister(prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Name: "kube_events_exporter_informers_synced",
+			Help: "Whether the informers have synced their caches with the apiserver",
+		},
+		func() float64 {
+			if collector.HasSynced() {
+				return 1
+			}
+			return 0
+		},
+	))
+	return collector, nil
+}
+
+// Cluster returns the name of the cluster the Events are collected from.
+func (collector *EventCollector) Cluster() string {
+	return collector.cluster
 }
 
 // Describe implements the prometheus.Collector interface.
@@ -74,48 +178,225 @@ func (collector *EventCollector) Collect(ch chan<- prometheus.Metric) {
 	collector.metrics.eventsTotal.Collect(ch)
 }
 
-// Run starts updating EventCollector metrics.
+// CustomMetrics returns the collectors of the user-defined metrics fed by the
+// EventCollector.
+func (collector *EventCollector) CustomMetrics() []prometheus.Collector {
+	collectors := make([]prometheus.Collector, 0, len(collector.customMetrics))
+	for _, customMetric := range collector.customMetrics {
+		collectors = append(collectors, customMetric)
+	}
+	return collectors
+}
+
+// Run starts updating EventCollector metrics and sending Events to the
+// configured sinks.
 func (collector *EventCollector) Run(stopCh <-chan struct{}) {
+	go func() {
+		<-stopCh
+		collector.cancel()
+	}()
+
+	for _, queue := range collector.queues {
+		go queue.Run(stopCh)
+	}
+
 	for _, informer := range collector.informers {
-		go informer.Run(stopCh)
+		collector.informersWG.Add(1)
+		go func(informer eventInformer) {
+			defer collector.informersWG.Done()
+			informer.Run(stopCh)
+		}(informer)
 	}
 }
 
-func (collector *EventCollector) newEventInformer(ns, evType string) cache.SharedIndexInformer {
-	return informer.NewInstrumentedEventInformer(
-		collector.kclient,
-		metav1.NamespaceAll,
-		collector.metrics.listWatchMetrics,
-		0,
-		cache.Indexers{},
-		func(list *metav1.ListOptions) {
-			filterInvolvedObjectNs(list, ns)
-			filterEventType(list, evType)
-		},
-	)
+// InformersStatus returns the status of the Event informers.
+func (collector *EventCollector) InformersStatus() []informer.Status {
+	statuses := make([]informer.Status, 0, len(collector.informers))
+	for _, inf := range collector.informers {
+		status := informer.GetStatus(inf, inf.fieldSelector)
+		status.Cluster = collector.cluster
+		statuses = append(statuses, status)
+	}
+	return statuses
 }
 
+// HasSynced returns true once all the informers have synced their caches.
+func (collector *EventCollector) HasSynced() bool {
+	for _, inf := range collector.informers {
+		if !inf.HasSynced() {
+			return false
+		}
+	}
+	return true
+}
+
+// Shutdown waits for the informers to stop and flushes the queues of the
+// configured sinks. It must be called once the channel passed to Run is
+// closed. The Events that couldn't be sent before ctx is done are written to
+// the dead letter logs of the sinks.
+func (collector *EventCollector) Shutdown(ctx context.Context) {
+	informersStopped := make(chan struct{})
+	go func() {
+		collector.informersWG.Wait()
+		close(informersStopped)
+	}()
+
+	select {
+	case <-informersStopped:
+	case <-ctx.Done():
+		klog.Errorf("timed out waiting for informers to stop")
+	}
+
+	var wg sync.WaitGroup
+	for _, queue := range collector.queues {
+		wg.Add(1)
+		go func(queue *configuredSink) {
+			defer wg.Done()
+			queue.Flush(ctx)
+		}(queue)
+	}
+	wg.Wait()
+}
+
+// eventInformer is an informer of the Events matching a field selector.
+type eventInformer struct {
+	cache.SharedIndexInformer
+	fieldSelector string
+}
+
+// ListEvents returns the cached Events matching the selector which were last
+// seen at or after since, the most recent first.
+func (collector *EventCollector) ListEvents(selector informer.Selector, since time.Time) ([]informer.ClusterEvent, error) {
+	var events []informer.ClusterEvent
+	seen := map[types.UID]bool{}
+	for _, inf := range collector.informers {
+		infEvents, err := informer.NewEventLister(inf.GetIndexer()).List(selector)
+		if err != nil {
+			return nil, errors.Wrapf(err, "list events matching %q", inf.fieldSelector)
+		}
+
+		for _, ev := range infEvents {
+			// The informers can watch overlapping sets of Events.
+			if seen[ev.UID] || sink.LastSeen(ev).Before(since) {
+				continue
+			}
+			seen[ev.UID] = true
+			events = append(events, informer.ClusterEvent{Cluster: collector.cluster, Event: ev})
+		}
+	}
+
+	sortEvents(events)
+	return events, nil
+}
+
+// sortEvents sorts Events by last seen time, the most recent first, in the
+// order expected by the continue tokens of the Events API.
+func sortEvents(events []informer.ClusterEvent) {
+	sort.Slice(events, func(i, j int) bool {
+		iLastSeen, jLastSeen := sink.LastSeen(events[i].Event), sink.LastSeen(events[j].Event)
+		if !iLastSeen.Equal(jLastSeen) {
+			return iLastSeen.After(jLastSeen)
+		}
+		if events[i].Cluster != events[j].Cluster {
+			return events[i].Cluster < events[j].Cluster
+		}
+		if events[i].Namespace != events[j].Namespace {
+			return events[i].Namespace < events[j].Namespace
+		}
+		if events[i].Name != events[j].Name {
+			return events[i].Name < events[j].Name
+		}
+		return events[i].UID < events[j].UID
+	})
+}
+
+func (collector *EventCollector) newEventInformer(ns, evType string) eventInformer {
+	tweakListOptions := func(list *metav1.ListOptions) {
+		filterInvolvedObjectNs(list, ns)
+		filterEventType(list, evType)
+	}
+	list := metav1.ListOptions{}
+	tweakListOptions(&list)
+
+	return eventInformer{
+		SharedIndexInformer: informer.NewInstrumentedEventInformer(
+			collector.kclient,
+			metav1.NamespaceAll,
+			collector.metrics.listWatchMetrics,
+			0,
+			cache.Indexers{},
+			tweakListOptions,
+			collector.transform.Transform,
+			collector.listOptions,
+		),
+		fieldSelector: strings.TrimPrefix(list.FieldSelector, ","),
+	}
+}
+
+// eventHandler returns the handler recording the new occurrences of the
+// Events, computed against their last seen count. After a watch failure, the
+// reflector of an informer lists the Events again and the handler is called
+// with the Events that changed in the meantime, so that no occurrence is lost.
 func (collector *EventCollector) eventHandler() cache.ResourceEventHandler {
-	return cache.FilteringResourceEventHandler{
-		FilterFunc: collector.filter.filter,
-		Handler: &cache.ResourceEventHandlerFuncs{
-			AddFunc: func(obj interface{}) {
-				collector.lock.Lock()
-				defer collector.lock.Unlock()
-
-				ev := obj.(*v1.Event)
-				collector.metrics.increaseEventsTotal(ev, 1)
-			},
-			UpdateFunc: func(oldObj, newObj interface{}) {
-				collector.lock.Lock()
-				defer collector.lock.Unlock()
-
-				oldEv := oldObj.(*v1.Event)
-				newEv := newObj.(*v1.Event)
-				nbNew := updatedEventNb(oldEv, newEv)
-				collector.metrics.increaseEventsTotal(newEv, float64(nbNew))
-			},
+	return &cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			collector.handleEvent(obj.(*v1.Event))
 		},
+		UpdateFunc: func(_, newObj interface{}) {
+			collector.handleEvent(newObj.(*v1.Event))
+		},
+		DeleteFunc: func(obj interface{}) {
+			ev, tombstone, ok := informer.DeletedEvent(obj)
+			if !ok {
+				return
+			}
+			collector.metrics.deletedTotal.WithLabelValues(strconv.FormatBool(tombstone)).Inc()
+
+			// Events usually expire after the event TTL of the
+			// apiserver.
+			collector.lock.Lock()
+			defer collector.lock.Unlock()
+			collector.counts.delete(ev.UID, time.Now())
+		},
+	}
+}
+
+// handleEvent records the new occurrences of an Event added or updated in the
+// informer caches. The count of the Events emitted before the start of the
+// exporter is kept to compute their new occurrences, but they aren't recorded.
+func (collector *EventCollector) handleEvent(ev *v1.Event) {
+	if !collector.filter.included(ev) {
+		return
+	}
+
+	collector.lock.Lock()
+	defer collector.lock.Unlock()
+
+	nbNew, seen := collector.counts.update(ev)
+	// Count only Events that were freshly emitted and not reconciled
+	// during the start of the informer.
+	if reconciledEvent(ev, collector.filter.creationTimestamp) {
+		return
+	}
+	if !seen {
+		nbNew = collector.filter.addedEventNb(ev)
+	}
+	// Updates not raising the count, such as the Events replayed by a
+	// relist, aren't recorded.
+	if nbNew <= 0 {
+		return
+	}
+	collector.record(ev, float64(nbNew))
+}
+
+// record sends an Event that occurred nbNew times since it was last seen to
+// all the sinks.
+func (collector *EventCollector) record(ev *v1.Event, nbNew float64) {
+	for _, s := range collector.sinks {
+		err := s.OnEvent(collector.ctx, ev, nbNew)
+		if err != nil {
+			klog.Errorf("failed to send event %s/%s to sink: %v", ev.Namespace, ev.Name, err)
+		}
 	}
 }
 
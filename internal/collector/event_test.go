@@ -17,9 +17,24 @@ limitations under the License.
 package collector
 
 import (
+	"context"
+	"reflect"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rhobs/kube-events-exporter/internal/sink"
+	"github.com/rhobs/kube-events-exporter/pkg/informer"
 
 	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
 )
 
 func TestUpdatedEventNb(t *testing.T) {
@@ -72,3 +87,331 @@ func TestUpdatedEventNb(t *testing.T) {
 		})
 	}
 }
+
+func newTestEvent(name, reason string, lastSeen time.Time) *v1.Event {
+	return &v1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+			UID:       types.UID(name),
+		},
+		InvolvedObject: v1.ObjectReference{Kind: "Pod", Namespace: "default", Name: "pod"},
+		Reason:         reason,
+		LastTimestamp:  metav1.NewTime(lastSeen),
+	}
+}
+
+func TestListEvents(t *testing.T) {
+	now := time.Now()
+	old := newTestEvent("old", "BackOff", now.Add(-time.Hour))
+	recent := newTestEvent("recent", "BackOff", now.Add(-time.Minute))
+	other := newTestEvent("other", "Pulled", now)
+
+	// The same Event is cached by both informers.
+	collector := &EventCollector{}
+	for _, events := range [][]*v1.Event{{old, recent}, {recent, other}} {
+		inf := cache.NewSharedIndexInformer(nil, &v1.Event{}, 0, informer.EventIndexers())
+		for _, ev := range events {
+			err := inf.GetIndexer().Add(ev)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		collector.informers = append(collector.informers, eventInformer{SharedIndexInformer: inf})
+	}
+
+	testCases := []struct {
+		desc     string
+		selector informer.Selector
+		since    time.Time
+		expected []string
+	}{
+		{
+			desc:     "All",
+			expected: []string{"other", "recent", "old"},
+		},
+		{
+			desc:     "Reason",
+			selector: informer.Selector{Reason: "BackOff", Kind: "Pod"},
+			expected: []string{"recent", "old"},
+		},
+		{
+			desc:     "Since",
+			selector: informer.Selector{Reason: "BackOff"},
+			since:    now.Add(-10 * time.Minute),
+			expected: []string{"recent"},
+		},
+		{
+			desc:     "NoMatch",
+			selector: informer.Selector{Name: "deployment"},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+			events, err := collector.ListEvents(tc.selector, tc.since)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var names []string
+			for _, ev := range events {
+				names = append(names, ev.Name)
+			}
+			if !reflect.DeepEqual(names, tc.expected) {
+				t.Fatalf("expected events %v, got %v", tc.expected, names)
+			}
+		})
+	}
+}
+
+// deltaSink is a sink.Sink recording the deltas of the Events by name.
+type deltaSink map[string]float64
+
+func (s deltaSink) OnEvent(_ context.Context, ev *v1.Event, delta float64) error {
+	s[ev.Name] += delta
+	return nil
+}
+
+func TestEventHandler(t *testing.T) {
+	start := time.Now()
+	before := start.Add(-time.Hour)
+	after := start.Add(time.Minute)
+
+	newEvent := func(uid string, first, last time.Time, count int32) *v1.Event {
+		ev := newTestEvent("event", "BackOff", last)
+		ev.UID = types.UID(uid)
+		ev.FirstTimestamp = metav1.NewTime(first)
+		ev.Count = count
+		return ev
+	}
+	add := func(ev *v1.Event) func(cache.ResourceEventHandler) {
+		return func(h cache.ResourceEventHandler) { h.OnAdd(ev) }
+	}
+	update := func(oldEv, newEv *v1.Event) func(cache.ResourceEventHandler) {
+		return func(h cache.ResourceEventHandler) { h.OnUpdate(oldEv, newEv) }
+	}
+	del := func(obj interface{}) func(cache.ResourceEventHandler) {
+		return func(h cache.ResourceEventHandler) { h.OnDelete(obj) }
+	}
+
+	testCases := []struct {
+		desc     string
+		calls    []func(cache.ResourceEventHandler)
+		expected float64
+	}{
+		{
+			desc: "Updated",
+			calls: []func(cache.ResourceEventHandler){
+				add(newEvent("a", after, after, 1)),
+				update(newEvent("a", after, after, 1), newEvent("a", after, after, 4)),
+			},
+			expected: 4,
+		},
+		{
+			// The Event was reconciled before the start of the exporter
+			// and occurred 3 times since.
+			desc: "UpdatedReconciled",
+			calls: []func(cache.ResourceEventHandler){
+				add(newEvent("a", before, before, 2)),
+				update(newEvent("a", before, before, 2), newEvent("a", before, after, 5)),
+			},
+			expected: 3,
+		},
+		{
+			desc: "Recreated",
+			calls: []func(cache.ResourceEventHandler){
+				add(newEvent("a", after, after, 4)),
+				update(newEvent("a", after, after, 4), newEvent("b", after, after, 2)),
+			},
+			expected: 6,
+		},
+		{
+			desc: "AddedAfterStart",
+			calls: []func(cache.ResourceEventHandler){
+				add(newEvent("a", after, after, 6)),
+			},
+			expected: 6,
+		},
+		{
+			desc: "Reconciled",
+			calls: []func(cache.ResourceEventHandler){
+				add(newEvent("a", before, before, 2)),
+				update(newEvent("a", before, before, 2), newEvent("a", before, before, 2)),
+			},
+		},
+		{
+			// A relist lost the Event, which occurred 3 times before
+			// being added back by the next relist.
+			desc: "DeletedAndAddedBack",
+			calls: []func(cache.ResourceEventHandler){
+				add(newEvent("a", after, after, 2)),
+				del(cache.DeletedFinalStateUnknown{Key: "default/event", Obj: newEvent("a", after, after, 2)}),
+				add(newEvent("a", after, after, 5)),
+			},
+			expected: 5,
+		},
+		{
+			// A relist served from the watch cache returned an older
+			// version of the Event.
+			desc: "Stale",
+			calls: []func(cache.ResourceEventHandler){
+				add(newEvent("a", after, after, 3)),
+				update(newEvent("a", after, after, 3), newEvent("a", after, after, 2)),
+				update(newEvent("a", after, after, 2), newEvent("a", after, after, 4)),
+			},
+			expected: 4,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+			deltas := deltaSink{}
+			collector := newTestEventCollector(start, deltas)
+
+			handler := collector.eventHandler()
+			for _, call := range tc.calls {
+				call(handler)
+			}
+			if deltas["event"] != tc.expected {
+				t.Fatalf("expected %v occurrences, got %v", tc.expected, deltas["event"])
+			}
+		})
+	}
+}
+
+func newTestEventCollector(start time.Time, sinks ...sink.Sink) *EventCollector {
+	collector := &EventCollector{
+		filter: eventFilter{
+			creationTimestamp: start,
+			apiGroups:         []string{""},
+			controllers:       []string{""},
+		},
+		metrics: newExporterMetrics(prometheus.NewRegistry(), false, nil),
+		sinks:   sinks,
+		counts:  newEventCounts(),
+	}
+	collector.ctx, collector.cancel = context.WithCancel(context.Background())
+	return collector
+}
+
+// blockingSink is a sink.Sink blocking until the context is done, like a
+// queue with the block drop policy once it is full.
+type blockingSink struct{}
+
+func (blockingSink) OnEvent(ctx context.Context, _ *v1.Event, _ float64) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestEventHandlerBlockingSinkStopped(t *testing.T) {
+	start := time.Now()
+	collector := newTestEventCollector(start, blockingSink{})
+	stopCh := make(chan struct{})
+	collector.Run(stopCh)
+
+	handled := make(chan struct{})
+	go func() {
+		defer close(handled)
+		collector.handleEvent(newTestEvent("event", "BackOff", start.Add(time.Minute)))
+	}()
+
+	close(stopCh)
+	select {
+	case <-handled:
+	case <-time.After(wait.ForeverTestTimeout):
+		t.Fatal("timed out waiting for the handler to return")
+	}
+}
+
+func TestEventCollectorRelist(t *testing.T) {
+	start := time.Now()
+	newEvent := func(rv string, count int32) *v1.Event {
+		ev := newTestEvent("event", "BackOff", start.Add(time.Minute))
+		ev.FirstTimestamp = metav1.NewTime(start.Add(time.Minute))
+		ev.ResourceVersion = rv
+		ev.Count = count
+		return ev
+	}
+
+	// The Event is lost by the second list and occurs 4 times before the
+	// third list, each list following a watch expiry.
+	lists := []*v1.EventList{
+		{ListMeta: metav1.ListMeta{ResourceVersion: "1"}, Items: []v1.Event{*newEvent("1", 1)}},
+		{ListMeta: metav1.ListMeta{ResourceVersion: "3"}},
+		{ListMeta: metav1.ListMeta{ResourceVersion: "4"}, Items: []v1.Event{*newEvent("4", 6)}},
+	}
+	var lock sync.Mutex
+	watchers := make(chan *watch.FakeWatcher, len(lists))
+	lw := &cache.ListWatch{
+		ListFunc: func(metav1.ListOptions) (runtime.Object, error) {
+			lock.Lock()
+			defer lock.Unlock()
+			list := lists[0]
+			if len(lists) > 1 {
+				lists = lists[1:]
+			}
+			return list, nil
+		},
+		WatchFunc: func(metav1.ListOptions) (watch.Interface, error) {
+			w := watch.NewFake()
+			watchers <- w
+			return w, nil
+		},
+	}
+
+	deltas := deltaSink{}
+	collector := newTestEventCollector(start, deltas)
+	inf := cache.NewSharedIndexInformer(lw, &v1.Event{}, 0, cache.Indexers{})
+	inf.AddEventHandler(collector.eventHandler())
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go inf.Run(stopCh)
+
+	expired := &metav1.Status{
+		Status: metav1.StatusFailure,
+		Code:   410,
+		Reason: metav1.StatusReasonExpired,
+	}
+	w := <-watchers
+	w.Modify(newEvent("2", 2))
+	w.Error(expired)
+	w = <-watchers
+	w.Error(expired)
+	<-watchers
+
+	err := wait.PollImmediate(10*time.Millisecond, 10*time.Second, func() (bool, error) {
+		collector.lock.Lock()
+		defer collector.lock.Unlock()
+		return deltas["event"] == 6 && inf.LastSyncResourceVersion() == "4", nil
+	})
+	if err != nil {
+		collector.lock.Lock()
+		defer collector.lock.Unlock()
+		t.Fatalf("expected 6 occurrences, got %v", deltas["event"])
+	}
+}
+
+func TestEventHandlerDelete(t *testing.T) {
+	collector := newTestEventCollector(time.Now())
+	handler := collector.eventHandler()
+
+	ev := newTestEvent("event", "BackOff", time.Now())
+	handler.OnAdd(ev)
+	handler.OnDelete(ev)
+	handler.OnDelete(cache.DeletedFinalStateUnknown{Key: "default/other", Obj: newTestEvent("other", "BackOff", time.Now())})
+
+	for tombstone, expected := range map[string]float64{"false": 1, "true": 1} {
+		if got := testutil.ToFloat64(collector.metrics.deletedTotal.WithLabelValues(tombstone)); got != expected {
+			t.Errorf("expected %v deletions with tombstone %s, got %v", expected, tombstone, got)
+		}
+	}
+	if deleted := collector.counts.counts[ev.UID].deleted; deleted.IsZero() {
+		t.Error("expected count of deleted event to be marked as deleted")
+	}
+}
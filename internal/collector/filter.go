@@ -29,15 +29,9 @@ type eventFilter struct {
 	controllers       []string
 }
 
-func (f *eventFilter) filter(obj interface{}) bool {
-	ev := obj.(*v1.Event)
-
-	// Count only Events that were freshly emitted and not reconciled
-	// during the start of the informer.
-	if reconciledEvent(ev, f.creationTimestamp) {
-		return false
-	}
-
+// included returns true if the Event is emitted by an allowed controller about
+// an object of an allowed API group, regardless of when it was emitted.
+func (f *eventFilter) included(ev *v1.Event) bool {
 	if !includedObjectAPIGroup(ev, f.apiGroups) {
 		return false
 	}
@@ -52,6 +46,28 @@ func reconciledEvent(ev *v1.Event, t time.Time) bool {
 	return latest.Before(t)
 }
 
+// addedEventNb returns the number of new occurrences of an Event seen for the
+// first time. All its occurrences are new if it was first emitted after the
+// start of the exporter, which is the case of the Events created while the
+// watch was down. Otherwise only its last occurrence is counted.
+func (f *eventFilter) addedEventNb(ev *v1.Event) int32 {
+	count := getEventCount(ev)
+	if count < 1 || getEventFirstTimestamp(ev).Before(f.creationTimestamp.Truncate(time.Second)) {
+		return 1
+	}
+	return count
+}
+
+func getEventFirstTimestamp(ev *v1.Event) time.Time {
+	switch {
+	case !ev.FirstTimestamp.IsZero():
+		return ev.FirstTimestamp.Time
+	case !ev.EventTime.IsZero():
+		return ev.EventTime.Time
+	}
+	return ev.CreationTimestamp.Time
+}
+
 func getEventLatestTimestamp(ev *v1.Event) time.Time {
 	eventTimes := []time.Time{
 		ev.FirstTimestamp.Time,
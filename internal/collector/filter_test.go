@@ -79,6 +79,59 @@ func TestReconciledEvent(t *testing.T) {
 	}
 }
 
+func TestAddedEventNb(t *testing.T) {
+	now := time.Date(1970, time.January, 1, 0, 0, 0, 0, time.UTC)
+	filter := &eventFilter{creationTimestamp: now}
+
+	testCases := []struct {
+		desc     string
+		event    *v1.Event
+		expected int32
+	}{
+		{
+			desc: "CreatedBeforeStart",
+			event: &v1.Event{
+				FirstTimestamp: metav1.NewTime(now.Add(-time.Minute)),
+				LastTimestamp:  metav1.NewTime(now.Add(time.Minute)),
+				Count:          5,
+			},
+			expected: 1,
+		},
+		{
+			desc: "CreatedAfterStart",
+			event: &v1.Event{
+				FirstTimestamp: metav1.NewTime(now.Add(time.Minute)),
+				Count:          5,
+			},
+			expected: 5,
+		},
+		{
+			desc: "SeriesCreatedAfterStart",
+			event: &v1.Event{
+				EventTime: metav1.NewMicroTime(now.Add(time.Minute)),
+				Series:    &v1.EventSeries{Count: 3},
+			},
+			expected: 3,
+		},
+		{
+			desc:     "NoCount",
+			event:    &v1.Event{EventTime: metav1.NewMicroTime(now.Add(time.Minute))},
+			expected: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+			got := filter.addedEventNb(tc.event)
+			if got != tc.expected {
+				t.Fatalf("expected %d, got %d", tc.expected, got)
+			}
+		})
+	}
+}
+
 func TestGetEventLatestTimestamp(t *testing.T) {
 	now := time.Date(1970, time.January, 1, 0, 0, 0, 0, time.UTC)
 	older := now.Add(-time.Minute)
@@ -17,32 +17,191 @@ limitations under the License.
 package collector
 
 import (
+	"sort"
+	"strings"
+	"sync"
+
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/rhobs/kube-events-exporter/internal/relabel"
 	"github.com/rhobs/kube-events-exporter/pkg/informer"
 
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog"
 )
 
 type exporterMetrics struct {
-	eventsTotal      *prometheus.CounterVec
+	eventsTotal      *relabeledCounter
+	messageCategory  bool
 	listWatchMetrics *informer.ListWatchMetrics
+	cacheBytes       prometheus.Gauge
+	cachedEvents     *prometheus.GaugeVec
+	deletedTotal     *prometheus.CounterVec
 }
 
-func newExporterMetrics(exporterRegistry *prometheus.Registry) *exporterMetrics {
-	return &exporterMetrics{
-		eventsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
-			Name: "kube_events_total",
-			Help: "Count of all Kubernetes Events",
-		}, []string{"type", "involved_object_namespace", "involved_object_kind", "reason"}),
+func newExporterMetrics(exporterRegistry prometheus.Registerer, messageCategory bool, relabelRules []*relabel.Rule) *exporterMetrics {
+	labels := []string{"type", "involved_object_namespace", "involved_object_kind", "reason"}
+	if messageCategory {
+		labels = append(labels, "message_category")
+	}
+
+	metrics := &exporterMetrics{
+		eventsTotal: newRelabeledCounter(
+			"kube_events_total",
+			"Count of all Kubernetes Events",
+			labels,
+			relabelRules,
+		),
+		messageCategory:  messageCategory,
 		listWatchMetrics: informer.NewListWatchMetrics(exporterRegistry),
+		cacheBytes: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "kube_events_exporter_cache_bytes",
+				Help: "Approximate size in bytes of the Events stored in the informer caches",
+			},
+		),
+	}
+	metrics.cachedEvents = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "kube_events_exporter_cached_events",
+			Help: "Number of Events stored in the informer caches",
+		},
+		[]string{"type", "namespace"},
+	)
+	metrics.deletedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "kube_events_exporter_events_deleted_total",
+			Help: "Number of Events deleted from the informer caches, tombstone being true for the deletions missed while the watch was down",
+		},
+		[]string{"tombstone"},
+	)
+	exporterRegistry.MustRegister(metrics.cacheBytes, metrics.cachedEvents, metrics.deletedTotal)
+	return metrics
+}
+
+func (m *exporterMetrics) increaseEventsTotal(event *v1.Event, nbNew float64, category string) {
+	labels := model.LabelSet{
+		"type":                      model.LabelValue(event.Type),
+		"involved_object_namespace": model.LabelValue(event.InvolvedObject.Namespace),
+		"involved_object_kind":      model.LabelValue(event.InvolvedObject.Kind),
+		"reason":                    model.LabelValue(event.Reason),
+	}
+	if m.messageCategory {
+		labels["message_category"] = model.LabelValue(category)
+	}
+	m.eventsTotal.add(labels, nbNew)
+}
+
+// relabeledCounter is a counter partitioned by labels that are relabeled
+// before being increased. Unlike with a prometheus.CounterVec, relabeling can
+// change the label names of the series.
+type relabeledCounter struct {
+	name       string
+	help       string
+	labelNames []string
+	rules      []*relabel.Rule
+
+	lock   sync.RWMutex
+	descs  map[string]*prometheus.Desc
+	series map[model.Fingerprint]*relabeledSeries
+}
+
+type relabeledSeries struct {
+	desc        *prometheus.Desc
+	labelValues []string
+	value       float64
+}
+
+func newRelabeledCounter(name, help string, labelNames []string, rules []*relabel.Rule) *relabeledCounter {
+	return &relabeledCounter{
+		name:       name,
+		help:       help,
+		labelNames: labelNames,
+		rules:      rules,
+		descs:      make(map[string]*prometheus.Desc),
+		series:     make(map[model.Fingerprint]*relabeledSeries),
+	}
+}
+
+// Describe implements the prometheus.Collector interface. The counter is
+// unchecked when relabeling is configured since its label names are only known
+// at collection time.
+func (c *relabeledCounter) Describe(ch chan<- *prometheus.Desc) {
+	if len(c.rules) > 0 {
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	ch <- c.desc(c.labelNames)
+}
+
+// Collect implements the prometheus.Collector interface.
+func (c *relabeledCounter) Collect(ch chan<- prometheus.Metric) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	for _, series := range c.series {
+		m, err := prometheus.NewConstMetric(series.desc, prometheus.CounterValue, series.value, series.labelValues...)
+		if err != nil {
+			klog.Errorf("failed to collect metric %s: %v", c.name, err)
+			continue
+		}
+		ch <- m
+	}
+}
+
+func (c *relabeledCounter) add(labels model.LabelSet, value float64) {
+	if len(c.rules) > 0 {
+		labels = relabel.Process(labels, c.rules...)
+		if labels == nil {
+			return
+		}
+		// Like Prometheus after target relabeling, drop the temporary and
+		// empty labels. The names produced by labelmap are not validated by
+		// the rules, so drop the invalid ones as well as the cluster label
+		// added when registering the metric.
+		for name, value := range labels {
+			if strings.HasPrefix(string(name), model.ReservedLabelPrefix) || value == "" || !name.IsValid() || name == ClusterLabel {
+				delete(labels, name)
+			}
+		}
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	fingerprint := labels.Fingerprint()
+	series, ok := c.series[fingerprint]
+	if !ok {
+		names := make([]string, 0, len(labels))
+		for name := range labels {
+			names = append(names, string(name))
+		}
+		sort.Strings(names)
+
+		values := make([]string, 0, len(names))
+		for _, name := range names {
+			values = append(values, string(labels[model.LabelName(name)]))
+		}
+
+		series = &relabeledSeries{
+			desc:        c.desc(names),
+			labelValues: values,
+		}
+		c.series[fingerprint] = series
 	}
+	series.value += value
 }
 
-func (m *exporterMetrics) increaseEventsTotal(event *v1.Event, nbNew float64) {
-	m.eventsTotal.With(prometheus.Labels{
-		"type":                      event.Type,
-		"involved_object_namespace": event.InvolvedObject.Namespace,
-		"involved_object_kind":      event.InvolvedObject.Kind,
-		"reason":                    event.Reason,
-	}).Add(nbNew)
+// desc returns the cached description of the series with the given label
+// names. It must be called with the lock held.
+func (c *relabeledCounter) desc(labelNames []string) *prometheus.Desc {
+	key := strings.Join(labelNames, ",")
+	desc, ok := c.descs[key]
+	if !ok {
+		desc = prometheus.NewDesc(c.name, c.help, labelNames, nil)
+		c.descs[key] = desc
+	}
+	return desc
 }
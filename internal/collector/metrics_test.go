@@ -0,0 +1,125 @@
+/*
+Copyright 2020 Red Hat, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rhobs/kube-events-exporter/internal/config"
+	"github.com/rhobs/kube-events-exporter/internal/relabel"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestIncreaseEventsTotal(t *testing.T) {
+	rules, err := relabel.New([]config.RelabelConfig{
+		{
+			SourceLabels: []string{"reason"},
+			Regex:        stringPtr("(.*)-[0-9a-f]+"),
+			TargetLabel:  "reason",
+		},
+		{
+			Regex:  stringPtr("involved_object_namespace"),
+			Action: config.RelabelLabelDrop,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpRules, err := relabel.New([]config.RelabelConfig{
+		{
+			SourceLabels: []string{"reason"},
+			Regex:        stringPtr("(.*)-[0-9a-f]+"),
+			TargetLabel:  "__tmp",
+		},
+		{
+			Regex:       stringPtr("involved_object_(.*)"),
+			Replacement: stringPtr("object-$1"),
+			Action:      config.RelabelLabelMap,
+		},
+		{
+			SourceLabels: []string{"__tmp"},
+			TargetLabel:  "reason",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		desc     string
+		rules    []*relabel.Rule
+		expected string
+	}{
+		{
+			desc: "NoRelabel",
+			expected: `
+# HELP kube_events_total Count of all Kubernetes Events
+# TYPE kube_events_total counter
+kube_events_total{involved_object_kind="Pod",involved_object_namespace="default",reason="FailedSync-1a2b",type="Warning"} 1
+kube_events_total{involved_object_kind="Pod",involved_object_namespace="default",reason="FailedSync-3c4d",type="Warning"} 2
+`,
+		},
+		{
+			desc:  "Relabel",
+			rules: rules,
+			expected: `
+# HELP kube_events_total Count of all Kubernetes Events
+# TYPE kube_events_total counter
+kube_events_total{involved_object_kind="Pod",reason="FailedSync",type="Warning"} 3
+`,
+		},
+		{
+			desc:  "RelabelTemporaryLabel",
+			rules: tmpRules,
+			expected: `
+# HELP kube_events_total Count of all Kubernetes Events
+# TYPE kube_events_total counter
+kube_events_total{involved_object_kind="Pod",involved_object_namespace="default",reason="FailedSync",type="Warning"} 3
+`,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+			m := newExporterMetrics(prometheus.NewRegistry(), false, tc.rules)
+			for _, reason := range []string{"FailedSync-1a2b", "FailedSync-3c4d", "FailedSync-3c4d"} {
+				ev := &v1.Event{
+					Type:           "Warning",
+					Reason:         reason,
+					InvolvedObject: v1.ObjectReference{Kind: "Pod", Namespace: "default"},
+				}
+				m.increaseEventsTotal(ev, 1, "")
+			}
+
+			err := testutil.CollectAndCompare(m.eventsTotal, strings.NewReader(tc.expected))
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
@@ -0,0 +1,114 @@
+/*
+Copyright 2020 Red Hat, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rhobs/kube-events-exporter/internal/config"
+	"github.com/rhobs/kube-events-exporter/internal/rules"
+	"github.com/rhobs/kube-events-exporter/internal/sink"
+	"github.com/rhobs/kube-events-exporter/internal/sink/alertmanager"
+	"github.com/rhobs/kube-events-exporter/internal/sink/eventlog"
+	"github.com/rhobs/kube-events-exporter/internal/sink/otlp"
+	"github.com/rhobs/kube-events-exporter/internal/sink/webhook"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// eventsTotalSink is the sink.Sink increasing kube_events_total.
+type eventsTotalSink struct {
+	rule        *rules.Rule
+	classifiers messageClassifiers
+	metrics     *exporterMetrics
+}
+
+// OnEvent implements the sink.Sink interface.
+func (s *eventsTotalSink) OnEvent(_ context.Context, ev *v1.Event, nbNew float64) error {
+	if nbNew <= 0 {
+		return nil
+	}
+	if s.rule != nil && !s.rule.Match(ev) {
+		return nil
+	}
+
+	// Classify the message once per counted Event since regular
+	// expressions are expensive.
+	category := messageCategoryOther
+	if len(s.classifiers) > 0 {
+		category = s.classifiers.classify(ev.Message)
+	}
+	s.metrics.increaseEventsTotal(ev, nbNew, category)
+	return nil
+}
+
+// configuredSink is a sink declared in the configuration. Only the Events
+// matching its rule are queued.
+type configuredSink struct {
+	*sink.Queue
+	rule *rules.Rule
+}
+
+// OnEvent implements the sink.Sink interface.
+func (s *configuredSink) OnEvent(ctx context.Context, ev *v1.Event, nbNew float64) error {
+	if s.rule != nil && !s.rule.Match(ev) {
+		return nil
+	}
+	return s.Queue.OnEvent(ctx, ev, nbNew)
+}
+
+// newConfiguredSinks creates the sinks declared in the configuration for the
+// Events of the given cluster, each wrapped inside its own queue. Sinks
+// exporting metrics gather them from eventsGatherer.
+func newConfiguredSinks(cfgs []config.SinkConfig, cluster string, ruleSet *rules.RuleSet, exporterRegistry prometheus.Registerer, eventsGatherer prometheus.Gatherer) ([]*configuredSink, error) {
+	metrics := sink.NewQueueMetrics(exporterRegistry)
+
+	sinks := make([]*configuredSink, 0, len(cfgs))
+	for _, cfg := range cfgs {
+		var (
+			s   sink.Sink
+			err error
+		)
+		switch {
+		case cfg.EventLog != nil:
+			s, err = eventlog.NewWriter(cfg.EventLog, cluster)
+		case cfg.Webhook != nil:
+			s, err = webhook.New(cfg.Webhook, cluster)
+		case cfg.Alertmanager != nil:
+			s, err = alertmanager.New(cfg.Alertmanager, cluster)
+		case cfg.OTLP != nil:
+			s, err = otlp.New(cfg.OTLP, eventsGatherer, cluster)
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "create sink %s", cfg.Name)
+		}
+
+		queue, err := sink.NewQueue(cfg.Name, s, cfg.Queue, metrics)
+		if err != nil {
+			return nil, errors.Wrapf(err, "create queue of sink %s", cfg.Name)
+		}
+
+		sinks = append(sinks, &configuredSink{
+			Queue: queue,
+			rule:  ruleSet.Get(cfg.Rule),
+		})
+	}
+
+	return sinks, nil
+}
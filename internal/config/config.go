@@ -0,0 +1,773 @@
+/*
+Copyright 2020 Red Hat, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+)
+
+// Config is the content of the kube-events-exporter configuration file.
+type Config struct {
+	// Rules are named expressions evaluated against Events.
+	Rules []RuleConfig `json:"rules,omitempty"`
+	// EventsTotal configures the kube_events_total metric.
+	EventsTotal EventsTotalConfig `json:"eventsTotal,omitempty"`
+	// Metrics are additional metrics derived from Events.
+	Metrics []MetricConfig `json:"metrics,omitempty"`
+	// MessageClassifiers categorize Events based on their message. They are
+	// evaluated in order and the first match wins.
+	MessageClassifiers []MessageClassifierConfig `json:"messageClassifiers,omitempty"`
+	// Sinks receive the accepted Events.
+	Sinks []SinkConfig `json:"sinks,omitempty"`
+	// RemoteWrite pushes the metrics with the Prometheus remote write
+	// protocol.
+	RemoteWrite *RemoteWriteConfig `json:"remoteWrite,omitempty"`
+	// Cache configures how the Events are stored in the informer caches.
+	Cache CacheConfig `json:"cache,omitempty"`
+}
+
+// CacheConfig configures the transformation of the Events before they are
+// stored in the informer caches, to reduce the memory used by the exporter.
+// The rules, sinks and APIs only see the transformed Events.
+type CacheConfig struct {
+	// KeepUnusedFields keeps the managedFields, ownerReferences,
+	// finalizers, selfLink and generateName of the Events. They are
+	// dropped by default since the exporter doesn't use them.
+	KeepUnusedFields bool `json:"keepUnusedFields,omitempty"`
+	// DropAnnotations drops the annotations of the Events.
+	DropAnnotations bool `json:"dropAnnotations,omitempty"`
+	// DropLabels drops the labels of the Events.
+	DropLabels bool `json:"dropLabels,omitempty"`
+	// MaxMessageLength truncates the messages longer than the given number
+	// of bytes. Messages aren't truncated if it is 0.
+	MaxMessageLength int `json:"maxMessageLength,omitempty"`
+}
+
+// RemoteWriteConfig configures pushing the metrics to an endpoint implementing
+// the Prometheus remote write protocol.
+type RemoteWriteConfig struct {
+	// URL is the remote write endpoint.
+	URL string `json:"url"`
+	// Interval is the interval at which metrics are gathered and pushed.
+	// Defaults to 30s.
+	Interval Duration `json:"interval,omitempty"`
+	// ExporterMetrics enables pushing the metrics about the exporter itself
+	// in addition to the Event metrics.
+	ExporterMetrics bool `json:"exporterMetrics,omitempty"`
+	// ExternalLabels are added to each series not already having them.
+	ExternalLabels map[string]string `json:"externalLabels,omitempty"`
+	// Queue configures the in-memory queue of pending requests.
+	Queue RemoteWriteQueueConfig `json:"queue,omitempty"`
+
+	HTTPClientConfig `json:",inline"`
+}
+
+// RemoteWriteQueueConfig configures the in-memory queue of pending remote
+// write requests. The oldest request is dropped when the queue is full.
+type RemoteWriteQueueConfig struct {
+	// Capacity is the maximum number of pending requests. Defaults to 10.
+	Capacity int `json:"capacity,omitempty"`
+	// MaxRetries is the number of times a request is retried before it is
+	// dropped. Defaults to 10.
+	MaxRetries *int `json:"maxRetries,omitempty"`
+	// MinBackoff is the delay before the first retry. It is doubled on each
+	// retry. Defaults to 1s.
+	MinBackoff Duration `json:"minBackoff,omitempty"`
+	// MaxBackoff is the maximum delay between retries. Defaults to 1m.
+	MaxBackoff Duration `json:"maxBackoff,omitempty"`
+}
+
+// SinkConfig configures a sink receiving the accepted Events. Exactly one sink
+// type must be set.
+type SinkConfig struct {
+	Name string `json:"name"`
+	// Rule is the name of the rule selecting the Events sent to the sink.
+	// All accepted Events are sent if it is empty.
+	Rule  string      `json:"rule,omitempty"`
+	Queue QueueConfig `json:"queue,omitempty"`
+
+	EventLog     *EventLogConfig     `json:"eventLog,omitempty"`
+	Webhook      *WebhookConfig      `json:"webhook,omitempty"`
+	Alertmanager *AlertmanagerConfig `json:"alertmanager,omitempty"`
+	OTLP         *OTLPConfig         `json:"otlp,omitempty"`
+}
+
+// DropPolicy is the behavior of a sink queue when it is full.
+type DropPolicy string
+
+const (
+	// DropNewest drops the incoming Event.
+	DropNewest DropPolicy = "dropNewest"
+	// DropOldest drops the oldest queued Event.
+	DropOldest DropPolicy = "dropOldest"
+	// Block waits for the queue to have room for the incoming Event.
+	Block DropPolicy = "block"
+)
+
+// QueueConfig configures the queue buffering the Events sent to a sink.
+type QueueConfig struct {
+	// Capacity is the maximum number of queued Events. Defaults to 1000.
+	Capacity int `json:"capacity,omitempty"`
+	// DropPolicy is applied when the queue is full. Defaults to dropNewest.
+	DropPolicy DropPolicy `json:"dropPolicy,omitempty"`
+	// MaxRetries is the number of times sending an Event is retried before
+	// it is dropped. Defaults to 3.
+	MaxRetries *int `json:"maxRetries,omitempty"`
+	// MinBackoff is the delay before the first retry. It is doubled on each
+	// retry. Defaults to 100ms.
+	MinBackoff Duration `json:"minBackoff,omitempty"`
+	// MaxBackoff is the maximum delay between retries. Defaults to 10s.
+	MaxBackoff Duration `json:"maxBackoff,omitempty"`
+	// BatchSize is the maximum number of Events sent at once to sinks
+	// supporting batches. Defaults to 1.
+	BatchSize int `json:"batchSize,omitempty"`
+	// BatchTimeout is the maximum time an incomplete batch is waiting for
+	// more Events before being sent. Defaults to 1s.
+	BatchTimeout Duration `json:"batchTimeout,omitempty"`
+	// DeadLetterPath is the file the Events that failed to be sent after all
+	// retries are written to as JSON lines. They are discarded if it is
+	// empty.
+	DeadLetterPath string `json:"deadLetterPath,omitempty"`
+}
+
+// Duration is a time.Duration represented as a string such as 10s in the
+// configuration file.
+type Duration time.Duration
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	err := json.Unmarshal(b, &s)
+	if err != nil {
+		return err
+	}
+
+	duration, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(duration)
+	return nil
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// EventLogConfig configures the structured log of accepted Events.
+type EventLogConfig struct {
+	// Path is the file the Events are written to. Defaults to stdout.
+	Path string `json:"path,omitempty"`
+	// MaxSizeMB is the size in megabytes after which the file is rotated.
+	MaxSizeMB int `json:"maxSizeMB,omitempty"`
+	// MaxBackups is the number of rotated files to retain.
+	MaxBackups int `json:"maxBackups,omitempty"`
+	// Fields are the fields written for each Event. Defaults to all fields.
+	Fields []string `json:"fields,omitempty"`
+}
+
+// WebhookConfig configures a sink posting Events to an HTTP endpoint.
+type WebhookConfig struct {
+	// URL is the endpoint the Events are posted to.
+	URL string `json:"url"`
+	// Template is a Go text/template rendering the request body from a
+	// batch of Events. The Events are posted as a JSON document if it is
+	// empty.
+	Template string `json:"template,omitempty"`
+
+	HTTPClientConfig `json:",inline"`
+}
+
+// AlertmanagerConfig configures a sink posting Events as alerts to the
+// Alertmanager v2 API.
+type AlertmanagerConfig struct {
+	// URL is the base URL of Alertmanager.
+	URL string `json:"url"`
+	// AlertName is the alertname label of the alerts. Defaults to
+	// KubernetesEvent.
+	AlertName string `json:"alertName,omitempty"`
+	// ResolveTimeout is the time after which an alert is resolved if its
+	// Event isn't seen again. Defaults to 5m.
+	ResolveTimeout Duration `json:"resolveTimeout,omitempty"`
+	// Labels are added to each alert.
+	Labels map[string]string `json:"labels,omitempty"`
+	// Annotations are added to each alert.
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	HTTPClientConfig `json:",inline"`
+}
+
+// OTLPProtocol is the transport used to export OpenTelemetry data.
+type OTLPProtocol string
+
+const (
+	// OTLPGRPC exports with OTLP/gRPC.
+	OTLPGRPC OTLPProtocol = "grpc"
+	// OTLPHTTP exports with OTLP/HTTP using the binary protobuf encoding.
+	OTLPHTTP OTLPProtocol = "http/protobuf"
+)
+
+// OTLPConfig configures a sink exporting kube_events_total and the Events
+// with the OpenTelemetry protocol.
+type OTLPConfig struct {
+	// Endpoint is the host:port of the OTLP/gRPC receiver or the base URL
+	// of the OTLP/HTTP receiver.
+	Endpoint string `json:"endpoint"`
+	// Protocol is either grpc or http/protobuf. Defaults to grpc.
+	Protocol OTLPProtocol `json:"protocol,omitempty"`
+	// Insecure disables TLS for OTLP/gRPC.
+	Insecure bool `json:"insecure,omitempty"`
+	// Metrics enables exporting kube_events_total as an OTLP sum.
+	Metrics bool `json:"metrics,omitempty"`
+	// MetricsInterval is the interval at which metrics are exported.
+	// Defaults to 30s.
+	MetricsInterval Duration `json:"metricsInterval,omitempty"`
+	// Logs enables exporting each Event as an OTLP log record.
+	Logs bool `json:"logs,omitempty"`
+
+	HTTPClientConfig `json:",inline"`
+}
+
+// HTTPClientConfig configures the HTTP client used by a sink.
+type HTTPClientConfig struct {
+	// Headers are added to each request.
+	Headers map[string]string `json:"headers,omitempty"`
+	// BearerToken is sent in the Authorization header.
+	BearerToken string `json:"bearerToken,omitempty"`
+	// BearerTokenFile is read on each request to get the bearer token.
+	BearerTokenFile string     `json:"bearerTokenFile,omitempty"`
+	BasicAuth       *BasicAuth `json:"basicAuth,omitempty"`
+	TLSConfig       TLSConfig  `json:"tlsConfig,omitempty"`
+	// Timeout of the requests. Defaults to 10s.
+	Timeout Duration `json:"timeout,omitempty"`
+}
+
+// BasicAuth configures the HTTP basic authentication.
+type BasicAuth struct {
+	Username string `json:"username"`
+	Password string `json:"password,omitempty"`
+	// PasswordFile is read on each request to get the password.
+	PasswordFile string `json:"passwordFile,omitempty"`
+}
+
+// TLSConfig configures the TLS connections.
+type TLSConfig struct {
+	// CAFile is the CA certificate used to validate the server certificate.
+	CAFile string `json:"caFile,omitempty"`
+	// CertFile and KeyFile are the client certificate and key.
+	CertFile string `json:"certFile,omitempty"`
+	KeyFile  string `json:"keyFile,omitempty"`
+	// ServerName is used to verify the server certificate.
+	ServerName         string `json:"serverName,omitempty"`
+	InsecureSkipVerify bool   `json:"insecureSkipVerify,omitempty"`
+}
+
+// RuleConfig is a named expression selecting Events.
+type RuleConfig struct {
+	Name       string `json:"name"`
+	Expression string `json:"expression"`
+}
+
+// ClusterLabel is the label naming the cluster of the metrics when Events are
+// collected from several clusters. Metrics can't set it themselves.
+const ClusterLabel = "cluster"
+
+// EventsTotalConfig configures the kube_events_total metric.
+type EventsTotalConfig struct {
+	// Rule is the name of the rule an Event has to match to be counted.
+	// Defaults to counting all Events.
+	Rule string `json:"rule,omitempty"`
+	// MessageCategory adds the message_category label resulting from the
+	// message classifiers.
+	MessageCategory bool `json:"messageCategory,omitempty"`
+	// RelabelConfigs are applied in order to the labels of the metric before
+	// it is increased.
+	RelabelConfigs []RelabelConfig `json:"relabelConfigs,omitempty"`
+}
+
+// RelabelAction is the action performed by a relabel configuration.
+type RelabelAction string
+
+// Relabel actions supported by the exporter. They have the same semantics as
+// the Prometheus ones.
+const (
+	RelabelReplace   RelabelAction = "replace"
+	RelabelKeep      RelabelAction = "keep"
+	RelabelDrop      RelabelAction = "drop"
+	RelabelHashMod   RelabelAction = "hashmod"
+	RelabelLabelMap  RelabelAction = "labelmap"
+	RelabelLabelDrop RelabelAction = "labeldrop"
+	RelabelLabelKeep RelabelAction = "labelkeep"
+	RelabelLowercase RelabelAction = "lowercase"
+	RelabelUppercase RelabelAction = "uppercase"
+)
+
+// RelabelConfig is a Prometheus relabel configuration. Fields are named after
+// their Prometheus counterpart so that configurations can be shared.
+type RelabelConfig struct {
+	SourceLabels []string      `json:"source_labels,omitempty"`
+	Separator    *string       `json:"separator,omitempty"`
+	Regex        *string       `json:"regex,omitempty"`
+	Modulus      uint64        `json:"modulus,omitempty"`
+	TargetLabel  string        `json:"target_label,omitempty"`
+	Replacement  *string       `json:"replacement,omitempty"`
+	Action       RelabelAction `json:"action,omitempty"`
+}
+
+// setsLabel returns whether the relabel configuration sets the given label.
+// The labels produced by labelmap are only known for literal replacements.
+func (r *RelabelConfig) setsLabel(name string) bool {
+	switch r.Action {
+	case "", RelabelReplace, RelabelLowercase, RelabelUppercase, RelabelHashMod:
+		return r.TargetLabel == name
+	case RelabelLabelMap:
+		return r.Replacement != nil && *r.Replacement == name
+	}
+	return false
+}
+
+// MessageClassifierConfig maps the Event messages matching a regular
+// expression to a category.
+type MessageClassifierConfig struct {
+	Name     string `json:"name"`
+	Regex    string `json:"regex"`
+	Category string `json:"category"`
+}
+
+// MetricType is the type of a metric derived from Events.
+type MetricType string
+
+const (
+	// MetricTypeCounter is a metric increased by the value of each Event.
+	MetricTypeCounter MetricType = "counter"
+	// MetricTypeGauge is a metric set to the value of the latest Event.
+	MetricTypeGauge MetricType = "gauge"
+	// MetricTypeHistogram is a metric observing the value of each Event.
+	MetricTypeHistogram MetricType = "histogram"
+)
+
+// MetricConfig describes a metric derived from Events.
+type MetricConfig struct {
+	Name string     `json:"name"`
+	Help string     `json:"help,omitempty"`
+	Type MetricType `json:"type,omitempty"`
+	// Rule is the name of the rule an Event has to match to be observed.
+	// Defaults to observing all Events.
+	Rule    string        `json:"rule,omitempty"`
+	Labels  []LabelConfig `json:"labels,omitempty"`
+	Value   ValueConfig   `json:"value,omitempty"`
+	Buckets []float64     `json:"buckets,omitempty"`
+}
+
+// LabelConfig describes how to extract a label value from an Event. Exactly
+// one of Field and MessageRegex must be set.
+type LabelConfig struct {
+	Name string `json:"name"`
+	// Field is the dot separated path of an Event field, e.g.
+	// involvedObject.kind.
+	Field string `json:"field,omitempty"`
+	// MessageRegex is a regular expression whose first capture group on the
+	// Event message is used as value. Events not matching it are ignored.
+	MessageRegex string `json:"messageRegex,omitempty"`
+}
+
+// ValueConfig describes how to extract the value of a metric from an Event.
+type ValueConfig struct {
+	// MessageRegex is a regular expression whose first capture group on the
+	// Event message is parsed as value. Defaults to the number of new
+	// occurrences of the Event.
+	MessageRegex string `json:"messageRegex,omitempty"`
+}
+
+// Load reads and validates the configuration file at the given path. An
+// empty path results in an empty configuration.
+func Load(path string) (*Config, error) {
+	cfg := &Config{}
+	if path == "" {
+		return cfg, nil
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "read config file %s", path)
+	}
+
+	content, err = yaml.YAMLToJSON(content)
+	if err != nil {
+		return nil, errors.Wrapf(err, "convert config file %s to JSON", path)
+	}
+
+	// Reject unknown fields so that typos in the configuration don't go
+	// unnoticed.
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.DisallowUnknownFields()
+	err = decoder.Decode(cfg)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unmarshal config file %s", path)
+	}
+
+	err = cfg.validate()
+	if err != nil {
+		return nil, errors.Wrapf(err, "validate config file %s", path)
+	}
+
+	return cfg, nil
+}
+
+func (c *Config) validate() error {
+	names := make(map[string]struct{}, len(c.Rules))
+	for _, rule := range c.Rules {
+		if rule.Name == "" {
+			return errors.New("rule name must not be empty")
+		}
+		if _, ok := names[rule.Name]; ok {
+			return errors.Errorf("duplicate rule %q", rule.Name)
+		}
+		names[rule.Name] = struct{}{}
+	}
+
+	if c.EventsTotal.Rule != "" {
+		if _, ok := names[c.EventsTotal.Rule]; !ok {
+			return errors.Errorf("eventsTotal references unknown rule %q", c.EventsTotal.Rule)
+		}
+	}
+	for i, relabelCfg := range c.EventsTotal.RelabelConfigs {
+		if relabelCfg.setsLabel(ClusterLabel) {
+			return errors.Errorf("eventsTotal relabel config %d: label %q is reserved for the cluster of the Events", i, ClusterLabel)
+		}
+	}
+
+	classifiers := make(map[string]struct{}, len(c.MessageClassifiers))
+	for _, classifier := range c.MessageClassifiers {
+		if classifier.Name == "" {
+			return errors.New("message classifier name must not be empty")
+		}
+		if _, ok := classifiers[classifier.Name]; ok {
+			return errors.Errorf("duplicate message classifier %q", classifier.Name)
+		}
+		classifiers[classifier.Name] = struct{}{}
+
+		if classifier.Regex == "" || classifier.Category == "" {
+			return errors.Errorf("message classifier %q must set regex and category", classifier.Name)
+		}
+	}
+
+	if c.Cache.MaxMessageLength < 0 {
+		return errors.New("cache maxMessageLength must not be negative")
+	}
+
+	sinks := make(map[string]struct{}, len(c.Sinks))
+	for i := range c.Sinks {
+		sink := &c.Sinks[i]
+		if sink.Name == "" {
+			return errors.New("sink name must not be empty")
+		}
+		if _, ok := sinks[sink.Name]; ok {
+			return errors.Errorf("duplicate sink %q", sink.Name)
+		}
+		sinks[sink.Name] = struct{}{}
+
+		if sink.Rule != "" {
+			if _, ok := names[sink.Rule]; !ok {
+				return errors.Errorf("sink %q references unknown rule %q", sink.Name, sink.Rule)
+			}
+		}
+
+		err := sink.validate()
+		if err != nil {
+			return errors.Wrapf(err, "sink %q", sink.Name)
+		}
+	}
+
+	if c.RemoteWrite != nil {
+		err := c.RemoteWrite.validate()
+		if err != nil {
+			return errors.Wrap(err, "remoteWrite")
+		}
+	}
+
+	metrics := make(map[string]struct{}, len(c.Metrics))
+	for i := range c.Metrics {
+		metric := &c.Metrics[i]
+		if metric.Name == "" {
+			return errors.New("metric name must not be empty")
+		}
+		if !model.IsValidMetricName(model.LabelValue(metric.Name)) {
+			return errors.Errorf("invalid metric name %q", metric.Name)
+		}
+		if reservedMetricName(metric.Name) {
+			return errors.Errorf("metric name %q is reserved by the exporter", metric.Name)
+		}
+		if _, ok := metrics[metric.Name]; ok {
+			return errors.Errorf("duplicate metric %q", metric.Name)
+		}
+		metrics[metric.Name] = struct{}{}
+
+		err := metric.validate(names)
+		if err != nil {
+			return errors.Wrapf(err, "metric %q", metric.Name)
+		}
+	}
+
+	return nil
+}
+
+// reservedMetricName returns true if name is the name of kube_events_total or
+// starts like the names of the exporter, Go and process metrics, which can be
+// served together with the custom metrics.
+func reservedMetricName(name string) bool {
+	if name == "kube_events_total" {
+		return true
+	}
+	for _, prefix := range []string{"kube_events_exporter_", "go_", "process_"} {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *SinkConfig) validate() error {
+	types := 0
+	if s.EventLog != nil {
+		types++
+	}
+	if s.Webhook != nil {
+		types++
+		err := s.Webhook.validate()
+		if err != nil {
+			return errors.Wrap(err, "webhook")
+		}
+	}
+	if s.Alertmanager != nil {
+		types++
+		err := s.Alertmanager.validate()
+		if err != nil {
+			return errors.Wrap(err, "alertmanager")
+		}
+	}
+	if s.OTLP != nil {
+		types++
+		err := s.OTLP.validate()
+		if err != nil {
+			return errors.Wrap(err, "otlp")
+		}
+	}
+	if types != 1 {
+		return errors.New("exactly one sink type must be set")
+	}
+
+	return s.Queue.validate()
+}
+
+func (w *WebhookConfig) validate() error {
+	if w.URL == "" {
+		return errors.New("url must not be empty")
+	}
+	return w.HTTPClientConfig.validate()
+}
+
+func (a *AlertmanagerConfig) validate() error {
+	if a.URL == "" {
+		return errors.New("url must not be empty")
+	}
+	if a.AlertName == "" {
+		a.AlertName = "KubernetesEvent"
+	}
+	if a.ResolveTimeout < 0 {
+		return errors.New("resolveTimeout must not be negative")
+	}
+	if a.ResolveTimeout == 0 {
+		a.ResolveTimeout = Duration(5 * time.Minute)
+	}
+	return a.HTTPClientConfig.validate()
+}
+
+func (o *OTLPConfig) validate() error {
+	if o.Endpoint == "" {
+		return errors.New("endpoint must not be empty")
+	}
+
+	switch o.Protocol {
+	case "":
+		o.Protocol = OTLPGRPC
+	case OTLPGRPC, OTLPHTTP:
+	default:
+		return errors.Errorf("unknown protocol %q", o.Protocol)
+	}
+
+	if !o.Metrics && !o.Logs {
+		return errors.New("at least one of metrics and logs must be enabled")
+	}
+	if o.MetricsInterval < 0 {
+		return errors.New("metricsInterval must not be negative")
+	}
+	if o.MetricsInterval == 0 {
+		o.MetricsInterval = Duration(30 * time.Second)
+	}
+
+	return o.HTTPClientConfig.validate()
+}
+
+func (r *RemoteWriteConfig) validate() error {
+	if r.URL == "" {
+		return errors.New("url must not be empty")
+	}
+	if r.Interval < 0 {
+		return errors.New("interval must not be negative")
+	}
+	if r.Interval == 0 {
+		r.Interval = Duration(30 * time.Second)
+	}
+
+	for name := range r.ExternalLabels {
+		if !model.LabelName(name).IsValid() {
+			return errors.Errorf("invalid external label name %q", name)
+		}
+	}
+
+	q := &r.Queue
+	if q.Capacity < 0 {
+		return errors.New("queue capacity must not be negative")
+	}
+	if q.Capacity == 0 {
+		q.Capacity = 10
+	}
+	if q.MaxRetries == nil {
+		maxRetries := 10
+		q.MaxRetries = &maxRetries
+	}
+	if q.MinBackoff == 0 {
+		q.MinBackoff = Duration(time.Second)
+	}
+	if q.MaxBackoff == 0 {
+		q.MaxBackoff = Duration(time.Minute)
+	}
+	if q.MaxBackoff < q.MinBackoff {
+		return errors.New("maxBackoff must not be lower than minBackoff")
+	}
+
+	return r.HTTPClientConfig.validate()
+}
+
+func (h *HTTPClientConfig) validate() error {
+	if h.BearerToken != "" && h.BearerTokenFile != "" {
+		return errors.New("at most one of bearerToken and bearerTokenFile must be set")
+	}
+	if h.BasicAuth != nil {
+		if h.BearerToken != "" || h.BearerTokenFile != "" {
+			return errors.New("at most one of basicAuth and bearer token must be set")
+		}
+		if h.BasicAuth.Password != "" && h.BasicAuth.PasswordFile != "" {
+			return errors.New("at most one of password and passwordFile must be set")
+		}
+	}
+	if (h.TLSConfig.CertFile == "") != (h.TLSConfig.KeyFile == "") {
+		return errors.New("certFile and keyFile must be set together")
+	}
+	if h.Timeout == 0 {
+		h.Timeout = Duration(10 * time.Second)
+	}
+	return nil
+}
+
+func (q *QueueConfig) validate() error {
+	if q.Capacity < 0 {
+		return errors.New("queue capacity must not be negative")
+	}
+	if q.Capacity == 0 {
+		q.Capacity = 1000
+	}
+
+	switch q.DropPolicy {
+	case "":
+		q.DropPolicy = DropNewest
+	case DropNewest, DropOldest, Block:
+	default:
+		return errors.Errorf("unknown drop policy %q", q.DropPolicy)
+	}
+
+	if q.MaxRetries == nil {
+		maxRetries := 3
+		q.MaxRetries = &maxRetries
+	}
+	if q.MinBackoff == 0 {
+		q.MinBackoff = Duration(100 * time.Millisecond)
+	}
+	if q.MaxBackoff == 0 {
+		q.MaxBackoff = Duration(10 * time.Second)
+	}
+	if q.MaxBackoff < q.MinBackoff {
+		return errors.New("maxBackoff must not be lower than minBackoff")
+	}
+
+	if q.BatchSize < 0 {
+		return errors.New("batchSize must not be negative")
+	}
+	if q.BatchSize == 0 {
+		q.BatchSize = 1
+	}
+	if q.BatchTimeout == 0 {
+		q.BatchTimeout = Duration(time.Second)
+	}
+
+	return nil
+}
+
+func (m *MetricConfig) validate(rules map[string]struct{}) error {
+	switch m.Type {
+	case "":
+		m.Type = MetricTypeCounter
+	case MetricTypeCounter, MetricTypeGauge, MetricTypeHistogram:
+	default:
+		return errors.Errorf("unknown metric type %q", m.Type)
+	}
+
+	if m.Help == "" {
+		m.Help = "Metric derived from Kubernetes Events."
+	}
+
+	if m.Rule != "" {
+		if _, ok := rules[m.Rule]; !ok {
+			return errors.Errorf("unknown rule %q", m.Rule)
+		}
+	}
+
+	for _, label := range m.Labels {
+		if label.Name == ClusterLabel {
+			return errors.Errorf("label %q is reserved for the cluster of the Events", label.Name)
+		}
+		if (label.Field == "") == (label.MessageRegex == "") {
+			return errors.Errorf("label %q must set exactly one of field and messageRegex", label.Name)
+		}
+	}
+
+	if len(m.Buckets) > 0 && m.Type != MetricTypeHistogram {
+		return errors.New("buckets can only be set on histograms")
+	}
+
+	return nil
+}
@@ -0,0 +1,322 @@
+/*
+Copyright 2020 Red Hat, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kube-events-exporter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	testCases := []struct {
+		desc    string
+		content string
+		valid   bool
+	}{
+		{
+			desc: "Valid",
+			content: `
+rules:
+- name: warnings
+  expression: type == "Warning"
+eventsTotal:
+  rule: warnings
+`,
+			valid: true,
+		},
+		{
+			desc: "UnknownField",
+			content: `
+rule:
+- name: warnings
+`,
+			valid: false,
+		},
+		{
+			desc: "DuplicateRule",
+			content: `
+rules:
+- name: warnings
+  expression: type == "Warning"
+- name: warnings
+  expression: type == "Normal"
+`,
+			valid: false,
+		},
+		{
+			desc: "Sink",
+			content: `
+sinks:
+- name: log
+  queue:
+    capacity: 10
+    dropPolicy: dropOldest
+    maxBackoff: 1m
+  eventLog: {}
+`,
+			valid: true,
+		},
+		{
+			desc: "SinkWithoutType",
+			content: `
+sinks:
+- name: log
+`,
+			valid: false,
+		},
+		{
+			desc: "SinkDropPolicy",
+			content: `
+sinks:
+- name: log
+  queue:
+    dropPolicy: unknown
+  eventLog: {}
+`,
+			valid: false,
+		},
+		{
+			desc: "Webhook",
+			content: `
+rules:
+- name: warnings
+  expression: type == "Warning"
+sinks:
+- name: chatops
+  rule: warnings
+  queue:
+    batchSize: 10
+    deadLetterPath: /tmp/dead-letter.log
+  webhook:
+    url: https://chatops.example.com/events
+    headers:
+      X-Source: kube-events-exporter
+    basicAuth:
+      username: exporter
+      passwordFile: /etc/webhook/password
+`,
+			valid: true,
+		},
+		{
+			desc: "WebhookWithoutURL",
+			content: `
+sinks:
+- name: chatops
+  webhook: {}
+`,
+			valid: false,
+		},
+		{
+			desc: "Alertmanager",
+			content: `
+sinks:
+- name: alertmanager
+  alertmanager:
+    url: http://alertmanager:9093
+    resolveTimeout: 15m
+    labels:
+      severity: warning
+`,
+			valid: true,
+		},
+		{
+			desc: "OTLP",
+			content: `
+sinks:
+- name: otel
+  otlp:
+    endpoint: otel-collector:4317
+    insecure: true
+    metrics: true
+    logs: true
+`,
+			valid: true,
+		},
+		{
+			desc: "OTLPNothingExported",
+			content: `
+sinks:
+- name: otel
+  otlp:
+    endpoint: otel-collector:4317
+`,
+			valid: false,
+		},
+		{
+			desc: "RemoteWrite",
+			content: `
+remoteWrite:
+  url: https://prometheus.example.com/api/v1/write
+  interval: 1m
+  exporterMetrics: true
+  externalLabels:
+    cluster: edge-1
+  queue:
+    capacity: 20
+  bearerTokenFile: /etc/remote-write/token
+`,
+			valid: true,
+		},
+		{
+			desc: "RemoteWriteInvalidExternalLabel",
+			content: `
+remoteWrite:
+  url: https://prometheus.example.com/api/v1/write
+  externalLabels:
+    cluster-name: edge-1
+`,
+			valid: false,
+		},
+		{
+			desc: "Cache",
+			content: `
+cache:
+  dropAnnotations: true
+  maxMessageLength: 256
+`,
+			valid: true,
+		},
+		{
+			desc: "CacheNegativeMessageLength",
+			content: `
+cache:
+  maxMessageLength: -1
+`,
+			valid: false,
+		},
+		{
+			desc: "MultipleSinkTypes",
+			content: `
+sinks:
+- name: alertmanager
+  alertmanager:
+    url: http://alertmanager:9093
+  eventLog: {}
+`,
+			valid: false,
+		},
+		{
+			desc: "SinkUnknownRule",
+			content: `
+sinks:
+- name: log
+  rule: warnings
+  eventLog: {}
+`,
+			valid: false,
+		},
+		{
+			desc: "UnknownRule",
+			content: `
+eventsTotal:
+  rule: warnings
+`,
+			valid: false,
+		},
+		{
+			desc: "Metric",
+			content: `
+metrics:
+- name: image_pull_failures_total
+`,
+			valid: true,
+		},
+		{
+			desc: "MetricEventsTotal",
+			content: `
+metrics:
+- name: kube_events_total
+`,
+			valid: false,
+		},
+		{
+			desc: "MetricExporterName",
+			content: `
+metrics:
+- name: kube_events_exporter_list_total
+`,
+			valid: false,
+		},
+		{
+			desc: "MetricClusterLabel",
+			content: `
+metrics:
+- name: image_pull_failures_total
+  labels:
+  - name: cluster
+    field: involvedObject.namespace
+`,
+			valid: false,
+		},
+		{
+			desc: "RelabelClusterLabel",
+			content: `
+eventsTotal:
+  relabelConfigs:
+  - source_labels: [involved_object_namespace]
+    target_label: cluster
+`,
+			valid: false,
+		},
+		{
+			desc: "RelabelLabelMapCluster",
+			content: `
+eventsTotal:
+  relabelConfigs:
+  - regex: involved_object_namespace
+    replacement: cluster
+    action: labelmap
+`,
+			valid: false,
+		},
+		{
+			desc: "MetricInvalidName",
+			content: `
+metrics:
+- name: image-pull-failures
+`,
+			valid: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			path := filepath.Join(dir, tc.desc+".yaml")
+			err := ioutil.WriteFile(path, []byte(tc.content), 0600)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = Load(path)
+			if tc.valid && err != nil {
+				t.Fatalf("expected valid configuration, got %v", err)
+			}
+			if !tc.valid && err == nil {
+				t.Fatal("expected invalid configuration")
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+/*
+Copyright 2020 Red Hat, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rhobs/kube-events-exporter/internal/version"
+
+	"k8s.io/client-go/tools/metrics"
+	"k8s.io/client-go/transport"
+)
+
+// UserAgent returns the user agent of the exporter's Kubernetes client.
+func UserAgent() string {
+	v := version.GetVersion()
+	if v == "" {
+		v = "unknown"
+	}
+	return fmt.Sprintf("kube-events-exporter/%s (%s/%s)", v, runtime.GOOS, runtime.GOARCH)
+}
+
+// RequestTimeout returns a transport wrapper bounding the duration of the
+// requests to the apiserver, including reading their response. Watch requests
+// are long-running and aren't affected.
+func RequestTimeout(timeout time.Duration) transport.WrapperFunc {
+	return func(rt http.RoundTripper) http.RoundTripper {
+		return &timeoutRoundTripper{next: rt, timeout: timeout}
+	}
+}
+
+type timeoutRoundTripper struct {
+	next    http.RoundTripper
+	timeout time.Duration
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (rt *timeoutRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if isWatch(req.URL) {
+		return rt.next.RoundTrip(req)
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), rt.timeout)
+	resp, err := rt.next.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	// The timeout also applies to reading the body, so the context is
+	// only released once it is closed.
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+func isWatch(u *url.URL) bool {
+	return u.Query().Get("watch") == "true" || strings.Contains(u.Path, "/watch/")
+}
+
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// RegisterClientMetrics registers the request latency and result metrics of
+// the client-go rest clients in the given prometheus.Registry. client-go only
+// accepts the metrics registered by the first call in a process.
+func RegisterClientMetrics(registry *prometheus.Registry) {
+	latency := &requestLatency{
+		histogram: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "kube_events_exporter_rest_client_request_duration_seconds",
+			Help:    "Latency of the requests to the apiserver by verb and host.",
+			Buckets: prometheus.ExponentialBuckets(0.005, 2, 12),
+		}, []string{"verb", "host"}),
+	}
+	result := &requestResult{
+		counter: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "kube_events_exporter_rest_client_requests_total",
+			Help: "Number of requests to the apiserver by status code, method and host.",
+		}, []string{"code", "method", "host"}),
+	}
+	registry.MustRegister(latency.histogram, result.counter)
+
+	metrics.Register(metrics.RegisterOpts{
+		RequestLatency: latency,
+		RequestResult:  result,
+	})
+}
+
+// requestLatency implements metrics.LatencyMetric. The URL path isn't used as
+// a label to keep the cardinality bounded.
+type requestLatency struct {
+	histogram *prometheus.HistogramVec
+}
+
+func (l *requestLatency) Observe(verb string, u url.URL, latency time.Duration) {
+	l.histogram.WithLabelValues(verb, u.Host).Observe(latency.Seconds())
+}
+
+// requestResult implements metrics.ResultMetric.
+type requestResult struct {
+	counter *prometheus.CounterVec
+}
+
+func (r *requestResult) Increment(code, method, host string) {
+	r.counter.WithLabelValues(code, method, host).Inc()
+}
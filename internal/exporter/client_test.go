@@ -0,0 +1,111 @@
+/*
+Copyright 2020 Red Hat, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package exporter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+func TestClientMetrics(t *testing.T) {
+	latency := &requestLatency{
+		histogram: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "request_duration_seconds",
+			Buckets: []float64{0.1, 1},
+		}, []string{"verb", "host"}),
+	}
+	result := &requestResult{
+		counter: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "requests_total",
+		}, []string{"code", "method", "host"}),
+	}
+
+	u := url.URL{Scheme: "https", Host: "10.0.0.1:6443", Path: "/api/v1/events"}
+	latency.Observe("GET", u, 500*time.Millisecond)
+	result.Increment("200", "GET", u.Host)
+	result.Increment("200", "GET", u.Host)
+
+	if got := testutil.CollectAndCount(latency.histogram); got != 1 {
+		t.Fatalf("expected 1 latency series, got %d", got)
+	}
+	if got := testutil.ToFloat64(result.counter.WithLabelValues("200", "GET", u.Host)); got != 2 {
+		t.Fatalf("expected 2 requests, got %v", got)
+	}
+}
+
+func TestUserAgent(t *testing.T) {
+	if userAgent := UserAgent(); !strings.HasPrefix(userAgent, "kube-events-exporter/") {
+		t.Fatalf("unexpected user agent %q", userAgent)
+	}
+}
+
+func TestRequestTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(wait.ForeverTestTimeout):
+		}
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: RequestTimeout(50 * time.Millisecond)(http.DefaultTransport)}
+
+	testCases := []struct {
+		desc    string
+		query   string
+		timeout bool
+	}{
+		{
+			desc:    "List",
+			timeout: true,
+		},
+		{
+			desc:  "Watch",
+			query: "?watch=true",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			req, err := http.NewRequest(http.MethodGet, server.URL+"/api/v1/events"+tc.query, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = client.Do(req.WithContext(ctx))
+			if err == nil {
+				t.Fatal("expected request to fail")
+			}
+			// Only the watch request outlives the request timeout.
+			if timedOut := ctx.Err() == nil; timedOut != tc.timeout {
+				t.Fatalf("expected request timeout %t, got error %v", tc.timeout, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,209 @@
+/*
+Copyright 2020 Red Hat, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package http
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/rhobs/kube-events-exporter/internal/sink"
+	"github.com/rhobs/kube-events-exporter/pkg/informer"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog"
+)
+
+const (
+	eventsAPIPath      = "/api/v1/events"
+	defaultEventsLimit = 100
+	maxEventsLimit     = 1000
+)
+
+// EventList is the response of the events API.
+type EventList struct {
+	Items []informer.ClusterEvent `json:"items"`
+	// Continue is the value of the continue parameter to get the next
+	// page. It is empty on the last page.
+	Continue string `json:"continue,omitempty"`
+}
+
+// ListEventsFunc lists the cached Events matching the selector which were
+// last seen at or after since, the most recent first, then ordered by cluster,
+// namespace, name and UID.
+type ListEventsFunc func(selector informer.Selector, since time.Time) ([]informer.ClusterEvent, error)
+
+// RegisterEventsAPIHandlers registers the read-only API serving the Events
+// listed by listEvents.
+func RegisterEventsAPIHandlers(mux *http.ServeMux, listEvents ListEventsFunc) {
+	mux.HandleFunc(eventsAPIPath, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		query := r.URL.Query()
+		selector := selectorFromQuery(query)
+
+		since, err := parseSince(query.Get("since"), time.Now())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		limit, err := parseIntParam(query.Get("limit"), defaultEventsLimit)
+		if err != nil || limit <= 0 || limit > maxEventsLimit {
+			http.Error(w, fmt.Sprintf("limit must be between 1 and %d", maxEventsLimit), http.StatusBadRequest)
+			return
+		}
+		var token *continueToken
+		if value := query.Get("continue"); value != "" {
+			token, err = decodeContinueToken(value)
+			if err != nil {
+				http.Error(w, "invalid continue parameter", http.StatusBadRequest)
+				return
+			}
+		}
+
+		events, err := listEvents(selector, since)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		start := 0
+		if token != nil {
+			start = sort.Search(len(events), func(i int) bool {
+				return token.before(events[i])
+			})
+		}
+		list := EventList{Items: []informer.ClusterEvent{}}
+		if start < len(events) {
+			end := start + limit
+			if end < len(events) {
+				list.Continue = newContinueToken(events[end-1]).encode()
+			} else {
+				end = len(events)
+			}
+			list.Items = events[start:end]
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(w).Encode(list)
+		if err != nil {
+			klog.Errorf("failed to write events API response: %v", err)
+		}
+	})
+}
+
+// selectorFromQuery returns the selector of the Events matching the filters
+// of the query parameters.
+func selectorFromQuery(query url.Values) informer.Selector {
+	return informer.Selector{
+		Namespace: query.Get("namespace"),
+		Kind:      query.Get("kind"),
+		Name:      query.Get("name"),
+		Reason:    query.Get("reason"),
+		Type:      query.Get("type"),
+	}
+}
+
+// parseSince parses the since parameter, either a duration before now or a
+// RFC 3339 timestamp.
+func parseSince(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err == nil {
+		return now.Add(-d), nil
+	}
+	since, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("since must be a duration or a RFC 3339 timestamp, got %q", value)
+	}
+	return since, nil
+}
+
+// continueToken identifies the last Event of a page. The next page starts
+// with the Events listed after it, so that the Events added or deleted in the
+// meantime don't shift the pages.
+type continueToken struct {
+	LastSeen  time.Time `json:"lastSeen"`
+	Cluster   string    `json:"cluster,omitempty"`
+	Namespace string    `json:"namespace"`
+	Name      string    `json:"name"`
+	UID       types.UID `json:"uid"`
+}
+
+func newContinueToken(ev informer.ClusterEvent) *continueToken {
+	return &continueToken{
+		LastSeen:  sink.LastSeen(ev.Event),
+		Cluster:   ev.Cluster,
+		Namespace: ev.Namespace,
+		Name:      ev.Name,
+		UID:       ev.UID,
+	}
+}
+
+func decodeContinueToken(value string) (*continueToken, error) {
+	content, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	token := &continueToken{}
+	err = json.Unmarshal(content, token)
+	if err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+func (t *continueToken) encode() string {
+	content, _ := json.Marshal(t)
+	return base64.RawURLEncoding.EncodeToString(content)
+}
+
+// before returns whether the Event of the token is listed before ev, in the
+// order of ListEventsFunc.
+func (t *continueToken) before(ev informer.ClusterEvent) bool {
+	if lastSeen := sink.LastSeen(ev.Event); !lastSeen.Equal(t.LastSeen) {
+		return lastSeen.Before(t.LastSeen)
+	}
+	if ev.Cluster != t.Cluster {
+		return ev.Cluster > t.Cluster
+	}
+	if ev.Namespace != t.Namespace {
+		return ev.Namespace > t.Namespace
+	}
+	if ev.Name != t.Name {
+		return ev.Name > t.Name
+	}
+	return ev.UID > t.UID
+}
+
+func parseIntParam(value string, defaultValue int) (int, error) {
+	if value == "" {
+		return defaultValue, nil
+	}
+	return strconv.Atoi(value)
+}
@@ -0,0 +1,185 @@
+/*
+Copyright 2020 Red Hat, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/rhobs/kube-events-exporter/pkg/informer"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestEventsAPI(t *testing.T) {
+	var events []informer.ClusterEvent
+	for i := 0; i < 5; i++ {
+		ev := &v1.Event{}
+		ev.Name = strconv.Itoa(i)
+		events = append(events, informer.ClusterEvent{Event: ev})
+	}
+	continueAfter := func(name string) string {
+		ev := &v1.Event{}
+		ev.Name = name
+		return newContinueToken(informer.ClusterEvent{Event: ev}).encode()
+	}
+
+	var selector informer.Selector
+	mux := http.NewServeMux()
+	RegisterEventsAPIHandlers(mux, func(s informer.Selector, _ time.Time) ([]informer.ClusterEvent, error) {
+		selector = s
+		return events, nil
+	})
+
+	testCases := []struct {
+		desc   string
+		query  string
+		status int
+		names  []string
+		next   string
+		reason string
+	}{
+		{
+			desc:   "All",
+			status: http.StatusOK,
+			names:  []string{"0", "1", "2", "3", "4"},
+		},
+		{
+			desc:   "FirstPage",
+			query:  "?limit=2&reason=BackOff",
+			status: http.StatusOK,
+			names:  []string{"0", "1"},
+			next:   continueAfter("1"),
+			reason: "BackOff",
+		},
+		{
+			desc:   "LastPage",
+			query:  "?limit=2&continue=" + continueAfter("3"),
+			status: http.StatusOK,
+			names:  []string{"4"},
+		},
+		{
+			// The page starts after the last Event returned even
+			// if it was deleted in the meantime.
+			desc:   "DeletedEvent",
+			query:  "?limit=2&continue=" + continueAfter("1a"),
+			status: http.StatusOK,
+			names:  []string{"2", "3"},
+			next:   continueAfter("3"),
+		},
+		{
+			desc:   "AfterLastPage",
+			query:  "?continue=" + continueAfter("4"),
+			status: http.StatusOK,
+			names:  []string{},
+		},
+		{
+			desc:   "InvalidContinue",
+			query:  "?continue=10",
+			status: http.StatusBadRequest,
+		},
+		{
+			desc:   "InvalidLimit",
+			query:  "?limit=0",
+			status: http.StatusBadRequest,
+		},
+		{
+			desc:   "InvalidSince",
+			query:  "?since=yesterday",
+			status: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, eventsAPIPath+tc.query, nil))
+			if rec.Code != tc.status {
+				t.Fatalf("expected status %d, got %d", tc.status, rec.Code)
+			}
+			if tc.status != http.StatusOK {
+				return
+			}
+
+			var list EventList
+			err := json.NewDecoder(rec.Body).Decode(&list)
+			if err != nil {
+				t.Fatal(err)
+			}
+			names := []string{}
+			for _, ev := range list.Items {
+				names = append(names, ev.Name)
+			}
+			if len(names) != len(tc.names) {
+				t.Fatalf("expected events %v, got %v", tc.names, names)
+			}
+			for i := range names {
+				if names[i] != tc.names[i] {
+					t.Fatalf("expected events %v, got %v", tc.names, names)
+				}
+			}
+			if list.Continue != tc.next {
+				t.Fatalf("expected continue %q, got %q", tc.next, list.Continue)
+			}
+			if selector.Reason != tc.reason {
+				t.Fatalf("expected reason selector %q, got %q", tc.reason, selector.Reason)
+			}
+		})
+	}
+}
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		desc     string
+		value    string
+		expected time.Time
+	}{
+		{
+			desc: "Empty",
+		},
+		{
+			desc:     "Duration",
+			value:    "15m",
+			expected: now.Add(-15 * time.Minute),
+		},
+		{
+			desc:     "Timestamp",
+			value:    "2020-06-01T10:00:00Z",
+			expected: time.Date(2020, 6, 1, 10, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+			since, err := parseSince(tc.value, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !since.Equal(tc.expected) {
+				t.Fatalf("expected %s, got %s", tc.expected, since)
+			}
+		})
+	}
+}
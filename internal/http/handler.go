@@ -17,30 +17,66 @@ limitations under the License.
 package http
 
 import (
+	"encoding/json"
 	"net/http"
+	"net/http/pprof"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rhobs/kube-events-exporter/pkg/informer"
+
+	"k8s.io/klog"
 )
 
 const (
-	metricsPath = "/metrics"
-	healthzPath = "/healthz"
+	healthzPath   = "/healthz"
+	readyzPath    = "/readyz"
+	pprofPath     = "/debug/pprof/"
+	informersPath = "/debug/informers"
 )
 
 // RegisterExporterMuxHandlers registers the handlers needed to serve the
-// exporter self metrics.
-func RegisterExporterMuxHandlers(mux *http.ServeMux, exporterRegistry *prometheus.Registry) {
-	metricsHandler := promhttp.HandlerFor(exporterRegistry, promhttp.HandlerOpts{})
-	mux.Handle(metricsPath, metricsHandler)
+// exporter self metrics on metricsPath, unless it is empty because they are
+// served with the Events metrics. If profiling is enabled, the pprof handlers
+// and an endpoint listing the status returned by informersStatus are
+// registered as well.
+func RegisterExporterMuxHandlers(mux *http.ServeMux, metricsPath string, exporterRegistry *prometheus.Registry, profiling bool, informersStatus func() []informer.Status) {
+	if metricsPath != "" {
+		metricsHandler := promhttp.HandlerFor(exporterRegistry, promhttp.HandlerOpts{})
+		mux.Handle(metricsPath, metricsHandler)
+	}
+
+	if !profiling {
+		return
+	}
+
+	// Add the same handlers as the net/http/pprof package registers in
+	// http.DefaultServeMux.
+	mux.HandleFunc(pprofPath, pprof.Index)
+	mux.HandleFunc(pprofPath+"cmdline", pprof.Cmdline)
+	mux.HandleFunc(pprofPath+"profile", pprof.Profile)
+	mux.HandleFunc(pprofPath+"symbol", pprof.Symbol)
+	mux.HandleFunc(pprofPath+"trace", pprof.Trace)
+
+	mux.HandleFunc(informersPath, func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		err := encoder.Encode(informersStatus())
+		if err != nil {
+			klog.Errorf("failed to write informers status: %v", err)
+		}
+	})
 }
 
 // RegisterEventsMuxHandlers registers the handlers needed to serve metrics
-// about Kubernetes Events.
-func RegisterEventsMuxHandlers(mux *http.ServeMux, eventsRegistry *prometheus.Registry, exporterRegistry *prometheus.Registry) {
+// about Kubernetes Events on metricsPath. The metrics are gathered from
+// eventsGatherer, which can also include the exporter registry to serve all
+// the metrics on a single path.
+func RegisterEventsMuxHandlers(mux *http.ServeMux, metricsPath string, eventsGatherer prometheus.Gatherer, exporterRegistry *prometheus.Registry) {
 	// Instrument metricsPath handler and register it inside the exporterRegistry.
 	metricsHandler := InstrumentMetricHandler(exporterRegistry,
-		promhttp.HandlerFor(eventsRegistry, promhttp.HandlerOpts{}),
+		promhttp.HandlerFor(eventsGatherer, promhttp.HandlerOpts{}),
 	)
 	mux.Handle(metricsPath, metricsHandler)
 
@@ -50,6 +86,55 @@ func RegisterEventsMuxHandlers(mux *http.ServeMux, eventsRegistry *prometheus.Re
 	})
 }
 
+// clusterReadiness is the readiness of a cluster the Events are collected
+// from.
+type clusterReadiness struct {
+	Cluster string `json:"cluster,omitempty"`
+	Ready   bool   `json:"ready"`
+}
+
+// RegisterReadinessHandler registers a handler on readyzPath reporting the
+// readiness of each cluster, which is ready once all its informers listed in
+// informersStatus have synced. The exporter is ready as long as one cluster is,
+// so that a failing cluster doesn't stop serving the metrics of the others.
+func RegisterReadinessHandler(mux *http.ServeMux, informersStatus func() []informer.Status) {
+	mux.HandleFunc(readyzPath, func(w http.ResponseWriter, _ *http.Request) {
+		clusters := readiness(informersStatus())
+
+		status := http.StatusServiceUnavailable
+		for _, cluster := range clusters {
+			if cluster.Ready {
+				status = http.StatusOK
+				break
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		err := json.NewEncoder(w).Encode(clusters)
+		if err != nil {
+			klog.Errorf("failed to write readiness: %v", err)
+		}
+	})
+}
+
+// readiness returns the readiness of the clusters of the informers, in the
+// order they are listed.
+func readiness(statuses []informer.Status) []clusterReadiness {
+	var clusters []clusterReadiness
+	index := map[string]int{}
+	for _, status := range statuses {
+		i, ok := index[status.Cluster]
+		if !ok {
+			i = len(clusters)
+			index[status.Cluster] = i
+			clusters = append(clusters, clusterReadiness{Cluster: status.Cluster, Ready: true})
+		}
+		clusters[i].Ready = clusters[i].Ready && status.Synced
+	}
+	return clusters
+}
+
 // InstrumentMetricHandler is a middleware that wraps the provided http.Handler
 // to observe requests sent to the exporter.
 func InstrumentMetricHandler(registry *prometheus.Registry, handler http.Handler) http.Handler {
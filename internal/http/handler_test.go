@@ -0,0 +1,94 @@
+/*
+Copyright 2020 Red Hat, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/rhobs/kube-events-exporter/pkg/informer"
+)
+
+func TestReadiness(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		statuses []informer.Status
+		status   int
+		clusters []clusterReadiness
+	}{
+		{
+			desc:     "SingleClusterSynced",
+			statuses: []informer.Status{{Synced: true}, {Synced: true}},
+			status:   http.StatusOK,
+			clusters: []clusterReadiness{{Ready: true}},
+		},
+		{
+			desc:     "SingleClusterNotSynced",
+			statuses: []informer.Status{{Synced: true}, {Synced: false}},
+			status:   http.StatusServiceUnavailable,
+			clusters: []clusterReadiness{{Ready: false}},
+		},
+		{
+			desc: "OneClusterSynced",
+			statuses: []informer.Status{
+				{Cluster: "a", Synced: false},
+				{Cluster: "b", Synced: true},
+				{Cluster: "a", Synced: true},
+			},
+			status:   http.StatusOK,
+			clusters: []clusterReadiness{{Cluster: "a", Ready: false}, {Cluster: "b", Ready: true}},
+		},
+		{
+			desc: "NoClusterSynced",
+			statuses: []informer.Status{
+				{Cluster: "a", Synced: false},
+				{Cluster: "b", Synced: false},
+			},
+			status:   http.StatusServiceUnavailable,
+			clusters: []clusterReadiness{{Cluster: "a", Ready: false}, {Cluster: "b", Ready: false}},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+			mux := http.NewServeMux()
+			RegisterReadinessHandler(mux, func() []informer.Status {
+				return tc.statuses
+			})
+
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, readyzPath, nil))
+			if rec.Code != tc.status {
+				t.Fatalf("expected status %d, got %d", tc.status, rec.Code)
+			}
+
+			var clusters []clusterReadiness
+			err := json.NewDecoder(rec.Body).Decode(&clusters)
+			if err != nil {
+				t.Fatalf("failed to decode readiness: %v", err)
+			}
+			if !reflect.DeepEqual(clusters, tc.clusters) {
+				t.Fatalf("expected clusters %+v, got %+v", tc.clusters, clusters)
+			}
+		})
+	}
+}
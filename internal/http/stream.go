@@ -0,0 +1,126 @@
+/*
+Copyright 2020 Red Hat, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rhobs/kube-events-exporter/internal/stream"
+
+	"k8s.io/klog"
+)
+
+const (
+	eventsStreamPath = "/api/v1/events/stream"
+	// keepAliveInterval is the interval at which comments are sent to
+	// Server-Sent Events subscribers and blank lines to newline delimited
+	// JSON subscribers, so that idle connections aren't closed by proxies.
+	keepAliveInterval = 30 * time.Second
+)
+
+// RegisterEventsStreamHandlers registers the handler streaming the Events
+// pushed by the broadcaster, as Server-Sent Events or newline delimited JSON.
+func RegisterEventsStreamHandlers(mux *http.ServeMux, broadcaster *stream.Broadcaster) {
+	mux.HandleFunc(eventsStreamPath, eventsStreamHandler(broadcaster, keepAliveInterval))
+}
+
+func eventsStreamHandler(broadcaster *stream.Broadcaster, keepAliveInterval time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+
+		query := r.URL.Query()
+		sse := query.Get("format") == "sse" || strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+
+		sub, err := broadcaster.Subscribe(selectorFromQuery(query))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		defer sub.Unsubscribe()
+
+		if sse {
+			w.Header().Set("Content-Type", "text/event-stream")
+		} else {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+		}
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		keepAlive := time.NewTicker(keepAliveInterval)
+		defer keepAlive.Stop()
+
+		for {
+			var err error
+			select {
+			case <-r.Context().Done():
+				return
+			case msg, ok := <-sub.C:
+				if !ok {
+					// The broadcaster was closed.
+					return
+				}
+				err = writeMessage(w, msg, sse)
+			case <-keepAlive.C:
+				err = writeKeepAlive(w, sse)
+			}
+			if err != nil {
+				klog.V(4).Infof("failed to write to events stream subscriber: %v", err)
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func writeMessage(w http.ResponseWriter, msg stream.Message, sse bool) error {
+	content, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	if sse {
+		content = append(append([]byte("data: "), content...), '\n', '\n')
+	} else {
+		content = append(content, '\n')
+	}
+	_, err = w.Write(content)
+	return err
+}
+
+// writeKeepAlive writes a comment to Server-Sent Events subscribers and a blank
+// line, to be ignored by the clients, to newline delimited JSON subscribers.
+func writeKeepAlive(w http.ResponseWriter, sse bool) error {
+	keepAlive := "\n"
+	if sse {
+		keepAlive = ": keep-alive\n\n"
+	}
+	_, err := w.Write([]byte(keepAlive))
+	return err
+}
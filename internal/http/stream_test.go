@@ -0,0 +1,169 @@
+/*
+Copyright 2020 Red Hat, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package http
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rhobs/kube-events-exporter/internal/stream"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestEventsStream(t *testing.T) {
+	testCases := []struct {
+		desc        string
+		query       string
+		contentType string
+		prefix      string
+	}{
+		{
+			desc:        "NDJSON",
+			query:       "?reason=BackOff",
+			contentType: "application/x-ndjson",
+		},
+		{
+			desc:        "SSE",
+			query:       "?reason=BackOff&format=sse",
+			contentType: "text/event-stream",
+			prefix:      "data: ",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+			broadcaster := stream.NewBroadcaster(1, 10, prometheus.NewRegistry())
+			mux := http.NewServeMux()
+			RegisterEventsStreamHandlers(mux, broadcaster)
+			server := httptest.NewServer(mux)
+			defer server.Close()
+
+			resp, err := http.Get(server.URL + eventsStreamPath + tc.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if got := resp.Header.Get("Content-Type"); got != tc.contentType {
+				t.Fatalf("expected content type %s, got %s", tc.contentType, got)
+			}
+
+			// The subscription is created before the headers are sent.
+			resp2, err := http.Get(server.URL + eventsStreamPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp2.Body.Close()
+			if resp2.StatusCode != http.StatusServiceUnavailable {
+				t.Fatalf("expected status %d for second subscriber, got %d", http.StatusServiceUnavailable, resp2.StatusCode)
+			}
+
+			for _, reason := range []string{"Pulled", "BackOff"} {
+				ev := &v1.Event{Reason: reason}
+				err := broadcaster.OnEvent(context.Background(), ev, 2)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			reader := bufio.NewReader(resp.Body)
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(line, tc.prefix) {
+				t.Fatalf("expected line starting with %q, got %q", tc.prefix, line)
+			}
+			var msg stream.Message
+			err = json.Unmarshal([]byte(strings.TrimPrefix(line, tc.prefix)), &msg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if msg.Event.Reason != "BackOff" || msg.Delta != 2 {
+				t.Fatalf("expected BackOff event occurring twice, got %+v", msg)
+			}
+
+			if tc.prefix != "" {
+				// Skip the blank line ending the Server-Sent Event.
+				_, err = reader.ReadString('\n')
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// Closing the broadcaster ends the stream.
+			broadcaster.Close()
+			_, err = reader.ReadString('\n')
+			if err != io.EOF {
+				t.Fatalf("expected stream to end, got %v", err)
+			}
+		})
+	}
+}
+
+func TestEventsStreamKeepAlive(t *testing.T) {
+	testCases := []struct {
+		desc      string
+		query     string
+		keepAlive string
+	}{
+		{
+			desc:      "NDJSON",
+			keepAlive: "\n",
+		},
+		{
+			desc:      "SSE",
+			query:     "?format=sse",
+			keepAlive: ": keep-alive\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+			broadcaster := stream.NewBroadcaster(1, 10, prometheus.NewRegistry())
+			defer broadcaster.Close()
+			server := httptest.NewServer(eventsStreamHandler(broadcaster, time.Millisecond))
+			defer server.Close()
+
+			resp, err := http.Get(server.URL + tc.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			line, err := bufio.NewReader(resp.Body).ReadString('\n')
+			if err != nil {
+				t.Fatal(err)
+			}
+			if line != tc.keepAlive {
+				t.Fatalf("expected keep-alive %q, got %q", tc.keepAlive, line)
+			}
+		})
+	}
+}
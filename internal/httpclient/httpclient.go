@@ -0,0 +1,146 @@
+/*
+Copyright 2020 Red Hat, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package httpclient builds HTTP clients from the sink configuration.
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rhobs/kube-events-exporter/internal/config"
+)
+
+// New returns an HTTP client configured from cfg. Files referenced by cfg are
+// read on each request so that rotated credentials are picked up.
+func New(cfg config.HTTPClientConfig) (*http.Client, error) {
+	tlsConfig, err := NewTLSConfig(cfg.TLSConfig)
+	if err != nil {
+		return nil, errors.Wrap(err, "create TLS config")
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	return &http.Client{
+		Transport: &roundTripper{cfg: cfg, next: transport},
+		Timeout:   time.Duration(cfg.Timeout),
+	}, nil
+}
+
+// NewTLSConfig returns the TLS configuration of the connections described by
+// cfg.
+func NewTLSConfig(cfg config.TLSConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		ServerName:         cfg.ServerName,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+
+	if cfg.CAFile != "" {
+		ca, err := ioutil.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "read CA file %s", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, errors.Errorf("no certificate found in CA file %s", cfg.CAFile)
+		}
+	}
+
+	if cfg.CertFile != "" {
+		// Load the client certificate on each handshake to pick up
+		// renewed certificates.
+		tlsConfig.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+			if err != nil {
+				return nil, errors.Wrapf(err, "load client certificate %s", cfg.CertFile)
+			}
+			return &cert, nil
+		}
+	}
+
+	return tlsConfig, nil
+}
+
+type roundTripper struct {
+	cfg  config.HTTPClientConfig
+	next http.RoundTripper
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (rt *roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	headers, err := Headers(rt.cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// RoundTrippers must not modify the original request.
+	req = req.Clone(req.Context())
+	for name, values := range headers {
+		req.Header[name] = values
+	}
+
+	return rt.next.RoundTrip(req)
+}
+
+// Headers returns the custom and authentication headers configured by cfg.
+func Headers(cfg config.HTTPClientConfig) (http.Header, error) {
+	headers := make(http.Header, len(cfg.Headers)+1)
+	for name, value := range cfg.Headers {
+		headers.Set(name, value)
+	}
+
+	bearerToken := cfg.BearerToken
+	if cfg.BearerTokenFile != "" {
+		token, err := readSecretFile(cfg.BearerTokenFile)
+		if err != nil {
+			return nil, err
+		}
+		bearerToken = token
+	}
+	if bearerToken != "" {
+		headers.Set("Authorization", "Bearer "+bearerToken)
+	}
+
+	if basicAuth := cfg.BasicAuth; basicAuth != nil {
+		password := basicAuth.Password
+		if basicAuth.PasswordFile != "" {
+			var err error
+			password, err = readSecretFile(basicAuth.PasswordFile)
+			if err != nil {
+				return nil, err
+			}
+		}
+		credentials := base64.StdEncoding.EncodeToString([]byte(basicAuth.Username + ":" + password))
+		headers.Set("Authorization", "Basic "+credentials)
+	}
+
+	return headers, nil
+}
+
+func readSecretFile(path string) (string, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", errors.Wrapf(err, "read secret file %s", path)
+	}
+	return strings.TrimSpace(string(content)), nil
+}
@@ -20,6 +20,8 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/spf13/pflag"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -37,17 +39,46 @@ const (
 	// ReportingControllerAll is the argument to specify to allow Event
 	// reported by all controllers.
 	ReportingControllerAll = ""
+
+	// ContentTypeJSON is the JSON content type of the Kubernetes API.
+	ContentTypeJSON = "application/json"
+
+	// ContentTypeProtobuf is the protobuf content type of the Kubernetes API.
+	ContentTypeProtobuf = "application/vnd.kubernetes.protobuf"
 )
 
 // Options are the configurable parameters for kube-events-exporter.
 type Options struct {
-	Apiserver    string
-	Kubeconfig   string
-	Host         string
-	Port         int
-	ExporterHost string
-	ExporterPort int
-	Version      bool
+	Apiserver           string
+	Kubeconfig          string
+	KubeconfigContexts  []string
+	KubeAPIQPS          float32
+	KubeAPIBurst        int
+	KubeAPITimeout      time.Duration
+	KubeAPIContentType  string
+	Host                string
+	Port                int
+	MetricsPath         string
+	ExporterHost        string
+	ExporterPort        int
+	ExporterMetricsPath string
+	SinglePort          bool
+	WebConfigFile       string
+	EnableProfiling     bool
+	EnableEventsAPI     bool
+	Version             bool
+	ConfigFile          string
+	ShutdownTimeout     time.Duration
+	ListPageSize        int64
+
+	EnableEventsStream         bool
+	EventsStreamMaxSubscribers int
+	EventsStreamBufferSize     int
+
+	PushGatewayURL      string
+	PushGatewayJob      string
+	PushGatewayGrouping map[string]string
+	PushGatewayInterval time.Duration
 
 	EventTypes               []string
 	InvolvedObjectAPIGroups  []string
@@ -77,12 +108,34 @@ func (o *Options) AddFlags() {
 	}
 
 	o.flags.StringVar(&o.Apiserver, "apiserver", "", "The URL of the apiserver to use as a master.")
-	o.flags.StringVar(&o.Kubeconfig, "kubeconfig", os.Getenv("KUBECONFIG"), "Absolute path to the kubeconfig file.")
+	o.flags.StringVar(&o.Kubeconfig, "kubeconfig", os.Getenv("KUBECONFIG"), "Absolute path to the kubeconfig file. With --kubeconfig-contexts, it can be a list of files merged as with KUBECONFIG.")
+	o.flags.StringArrayVar(&o.KubeconfigContexts, "kubeconfig-contexts", []string{}, "List of kubeconfig contexts to collect Events from, each cluster being named after its context and labeling its metrics. With several contexts, the event log sinks and the dead letter logs can't be written to files. Defaults to the current context without cluster label.")
+	o.flags.Float32Var(&o.KubeAPIQPS, "kube-api-qps", 5, "Maximum queries per second to the apiserver.")
+	o.flags.IntVar(&o.KubeAPIBurst, "kube-api-burst", 10, "Maximum burst of queries to the apiserver.")
+	o.flags.DurationVar(&o.KubeAPITimeout, "kube-api-timeout", 0, "Timeout of the requests to the apiserver, also sent with the list requests rounded up to the second. Watch requests aren't affected. Disabled if 0.")
+	o.flags.StringVar(&o.KubeAPIContentType, "kube-api-content-type", ContentTypeJSON, fmt.Sprintf("Content type of the requests to the apiserver, either %s or %s.", ContentTypeJSON, ContentTypeProtobuf))
 	o.flags.StringVar(&o.Host, "host", "0.0.0.0", "Host to expose Events metrics on.")
 	o.flags.IntVar(&o.Port, "port", 8080, "Port to expose Events metrics on.")
+	o.flags.StringVar(&o.MetricsPath, "metrics-path", "/metrics", "Path to expose Events metrics on.")
 	o.flags.StringVar(&o.ExporterHost, "exporter-host", "0.0.0.0", "Host to expose kube-events-exporter own metrics on.")
 	o.flags.IntVar(&o.ExporterPort, "exporter-port", 8081, "Port to expose kube-events-exporter own metrics on.")
+	o.flags.StringVar(&o.ExporterMetricsPath, "exporter-metrics-path", "/metrics", "Path to expose kube-events-exporter own metrics on.")
+	o.flags.StringVar(&o.WebConfigFile, "web.config.file", "", "Path to a web configuration file enabling TLS, basic auth and response headers, in the Prometheus exporter-toolkit format. Reloaded when it changes.")
+	o.flags.BoolVar(&o.EnableProfiling, "enable-profiling", false, "Serve the pprof handlers and the status of the informers under /debug on the exporter port.")
+	o.flags.BoolVar(&o.EnableEventsAPI, "enable-events-api", false, "Serve the cached Events as JSON on /api/v1/events on the Events metrics port.")
+	o.flags.BoolVar(&o.EnableEventsStream, "enable-events-stream", false, "Stream the accepted Events on /api/v1/events/stream on the Events metrics port.")
+	o.flags.IntVar(&o.EventsStreamMaxSubscribers, "events-stream-max-subscribers", 10, "Maximum number of concurrent subscribers to the Events stream.")
+	o.flags.IntVar(&o.EventsStreamBufferSize, "events-stream-buffer-size", 100, "Number of Events buffered for each subscriber to the Events stream before dropping them.")
+	o.flags.Int64Var(&o.ListPageSize, "list-page-size", 0, "Number of Events requested per page by the lists served from etcd, which are only the relists once the informers resourceVersion expired. The initial list and the relists at a known resourceVersion are served whole from the apiserver watch cache. Defaults to the client-go page size of 500.")
+	o.flags.BoolVar(&o.SinglePort, "single-port", false, "Expose kube-events-exporter own metrics on the host and port of the Events metrics. Both are merged if their paths are the same.")
 	o.flags.BoolVar(&o.Version, "version", false, "kube-events-exporter version information")
+	o.flags.StringVar(&o.ConfigFile, "config-file", "", "Path to the kube-events-exporter configuration file.")
+	o.flags.DurationVar(&o.ShutdownTimeout, "shutdown-timeout", 25*time.Second, "Maximum duration of the graceful shutdown. It should be lower than the termination grace period of the pod.")
+
+	o.flags.StringVar(&o.PushGatewayURL, "push-gateway-url", "", "URL of a Pushgateway to periodically push Events metrics to. Disabled if empty.")
+	o.flags.StringVar(&o.PushGatewayJob, "push-gateway-job", "kube-events-exporter", "Job name of the metrics pushed to the Pushgateway.")
+	o.flags.StringToStringVar(&o.PushGatewayGrouping, "push-gateway-grouping", map[string]string{}, "Grouping labels of the metrics pushed to the Pushgateway, such as cluster=ci-1234.")
+	o.flags.DurationVar(&o.PushGatewayInterval, "push-gateway-interval", 30*time.Second, "Interval at which metrics are pushed to the Pushgateway.")
 
 	o.flags.StringArrayVar(&o.EventTypes, "event-types", []string{EventTypeAll}, "List of allowed Event types. Defaults to all types.")
 	o.flags.StringArrayVar(&o.InvolvedObjectAPIGroups, "involved-object-api-groups", []string{APIGroupAll}, "List of allowed Event involved object API groups. Defaults to all API groups.")
@@ -93,7 +146,67 @@ func (o *Options) AddFlags() {
 // Parse parses the flag definitions from the argument list.
 func (o *Options) Parse() error {
 	err := o.flags.Parse(os.Args)
-	return err
+	if err != nil {
+		return err
+	}
+
+	for _, path := range []string{o.MetricsPath, o.ExporterMetricsPath} {
+		if !strings.HasPrefix(path, "/") {
+			return fmt.Errorf("metrics path %q must start with /", path)
+		}
+	}
+
+	if err := o.validateKubeconfigContexts(); err != nil {
+		return err
+	}
+
+	if o.KubeAPIQPS <= 0 || o.KubeAPIBurst <= 0 {
+		return fmt.Errorf("kube api qps and burst must be positive")
+	}
+
+	if o.KubeAPITimeout < 0 {
+		return fmt.Errorf("kube api timeout must not be negative")
+	}
+
+	if o.KubeAPIContentType != ContentTypeJSON && o.KubeAPIContentType != ContentTypeProtobuf {
+		return fmt.Errorf("kube api content type %q must be either %s or %s", o.KubeAPIContentType, ContentTypeJSON, ContentTypeProtobuf)
+	}
+
+	if o.ListPageSize < 0 {
+		return fmt.Errorf("list page size must not be negative")
+	}
+
+	if o.EnableEventsStream && (o.EventsStreamMaxSubscribers <= 0 || o.EventsStreamBufferSize <= 0) {
+		return fmt.Errorf("events stream max subscribers and buffer size must be positive")
+	}
+
+	if o.PushGatewayURL != "" && o.PushGatewayInterval <= 0 {
+		return fmt.Errorf("push gateway interval must be positive")
+	}
+	return nil
+}
+
+// validateKubeconfigContexts validates the options of the multi-cluster mode.
+func (o *Options) validateKubeconfigContexts() error {
+	if len(o.KubeconfigContexts) == 0 {
+		return nil
+	}
+
+	if o.Apiserver != "" {
+		return fmt.Errorf("apiserver can't be set with kubeconfig contexts")
+	}
+
+	seen := map[string]bool{}
+	for _, context := range o.KubeconfigContexts {
+		if context == "" {
+			return fmt.Errorf("kubeconfig contexts must not be empty")
+		}
+		if seen[context] {
+			return fmt.Errorf("duplicate kubeconfig context %q", context)
+		}
+		seen[context] = true
+	}
+	return nil
 }
 
 // Usage is the function called when an error occurs while parsing flags.
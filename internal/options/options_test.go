@@ -39,6 +39,64 @@ func TestOptionsParse(t *testing.T) {
 				"--exporter-port=8081",
 			},
 		},
+		{
+			Desc: "single port and metrics paths command line argument",
+			Args: []string{"./kube-events-exporter",
+				"--single-port",
+				"--metrics-path=/metrics",
+				"--exporter-metrics-path=/exporter-metrics",
+			},
+		},
+		{
+			Desc: "list page size command line argument",
+			Args: []string{"./kube-events-exporter", "--list-page-size=100"},
+		},
+		{
+			Desc: "kubeconfig contexts command line argument",
+			Args: []string{"./kube-events-exporter",
+				"--kubeconfig=/etc/kubeconfig/a:/etc/kubeconfig/b",
+				"--kubeconfig-contexts=prod-eu",
+				"--kubeconfig-contexts=prod-us",
+			},
+		},
+		{
+			Desc: "kube api command line argument",
+			Args: []string{"./kube-events-exporter",
+				"--kube-api-qps=20",
+				"--kube-api-burst=40",
+				"--kube-api-timeout=30s",
+				"--kube-api-content-type=application/vnd.kubernetes.protobuf",
+			},
+		},
+		{
+			Desc: "profiling command line argument",
+			Args: []string{"./kube-events-exporter", "--enable-profiling"},
+		},
+		{
+			Desc: "events API command line argument",
+			Args: []string{"./kube-events-exporter", "--enable-events-api"},
+		},
+		{
+			Desc: "events stream command line argument",
+			Args: []string{"./kube-events-exporter",
+				"--enable-events-stream",
+				"--events-stream-max-subscribers=5",
+				"--events-stream-buffer-size=1000",
+			},
+		},
+		{
+			Desc: "web config file command line argument",
+			Args: []string{"./kube-events-exporter", "--web.config.file=/etc/kube-events-exporter/web.yaml"},
+		},
+		{
+			Desc: "push gateway command line argument",
+			Args: []string{"./kube-events-exporter",
+				"--push-gateway-url=http://pushgateway:9091",
+				"--push-gateway-job=ci",
+				"--push-gateway-grouping=cluster=ci-1234,run=42",
+				"--push-gateway-interval=10s",
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -53,3 +111,37 @@ func TestOptionsParse(t *testing.T) {
 		}
 	}
 }
+
+func TestOptionsParseError(t *testing.T) {
+	tests := []struct {
+		Desc string
+		Args []string
+	}{
+		{
+			Desc: "zero push gateway interval command line argument",
+			Args: []string{"./kube-events-exporter",
+				"--push-gateway-url=http://pushgateway:9091",
+				"--push-gateway-interval=0s",
+			},
+		},
+		{
+			Desc: "negative push gateway interval command line argument",
+			Args: []string{"./kube-events-exporter",
+				"--push-gateway-url=http://pushgateway:9091",
+				"--push-gateway-interval=-10s",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		opts := NewOptions()
+		opts.AddFlags()
+
+		os.Args = test.Args
+
+		err := opts.Parse()
+		if err == nil {
+			t.Errorf("Test error for Desc: %s, expected an error.", test.Desc)
+		}
+	}
+}
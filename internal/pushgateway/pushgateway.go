@@ -0,0 +1,92 @@
+/*
+Copyright 2020 Red Hat, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package pushgateway periodically pushes metrics to a Prometheus Pushgateway.
+package pushgateway
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+
+	"k8s.io/klog"
+)
+
+const pushTimeout = 10 * time.Second
+
+// Pusher periodically pushes the metrics of a gatherer to a Pushgateway. The
+// metrics are pushed a last time when it stops, so that short-lived runs
+// don't lose the Events that occurred since the previous push.
+type Pusher struct {
+	pusher   *push.Pusher
+	url      string
+	interval time.Duration
+
+	failuresTotal prometheus.Counter
+}
+
+// New returns a Pusher pushing the metrics of gatherer to the Pushgateway at
+// url under the given job and grouping labels. Its own metrics are registered
+// in exporterRegistry.
+func New(url, job string, grouping map[string]string, interval time.Duration, gatherer prometheus.Gatherer, exporterRegistry *prometheus.Registry) *Pusher {
+	pusher := push.New(url, job).
+		Gatherer(gatherer).
+		Client(&http.Client{Timeout: pushTimeout})
+
+	for name, value := range grouping {
+		pusher = pusher.Grouping(name, value)
+	}
+
+	p := &Pusher{
+		pusher:   pusher,
+		url:      url,
+		interval: interval,
+		failuresTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "kube_events_exporter_push_gateway_failures_total",
+			Help: "Number of failed pushes to the Pushgateway",
+		}),
+	}
+	exporterRegistry.MustRegister(p.failuresTotal)
+	return p
+}
+
+// Run pushes the metrics at the configured interval until stopCh is closed,
+// then pushes them a last time.
+func (p *Pusher) Run(stopCh <-chan struct{}) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopCh:
+			p.push()
+			return
+		case <-ticker.C:
+			p.push()
+		}
+	}
+}
+
+func (p *Pusher) push() {
+	// Replace all the metrics of the group so that stale series are
+	// removed.
+	err := p.pusher.Push()
+	if err != nil {
+		p.failuresTotal.Inc()
+		klog.Errorf("failed to push metrics to %s: %v", p.url, err)
+	}
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2020 Red Hat, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pushgateway
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+func TestPusher(t *testing.T) {
+	type push struct {
+		method string
+		path   string
+		body   string
+	}
+	pushes := make(chan push, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		pushes <- push{method: r.Method, path: r.URL.Path, body: string(body)}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	registry := prometheus.NewRegistry()
+	eventsTotal := prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "kube_events_total",
+		Help: "Count of all Kubernetes Events",
+	})
+	registry.MustRegister(eventsTotal)
+
+	exporterRegistry := prometheus.NewRegistry()
+	grouping := map[string]string{"cluster": "ci-1234"}
+	// Use an interval long enough for only the final push to happen.
+	p := New(server.URL, "ci", grouping, time.Hour, registry, exporterRegistry)
+
+	stopCh := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		p.Run(stopCh)
+		close(done)
+	}()
+
+	eventsTotal.Add(2)
+	close(stopCh)
+
+	select {
+	case <-done:
+	case <-time.After(wait.ForeverTestTimeout):
+		t.Fatal("timed out waiting for the pusher to stop")
+	}
+
+	select {
+	case got := <-pushes:
+		if got.method != http.MethodPut {
+			t.Fatalf("expected PUT request, got %s", got.method)
+		}
+		expectedPath := "/metrics/job/ci/cluster/ci-1234"
+		if got.path != expectedPath {
+			t.Fatalf("expected push to %s, got %s", expectedPath, got.path)
+		}
+		if !strings.Contains(got.body, "kube_events_total") {
+			t.Fatalf("expected kube_events_total to be pushed, got %q", got.body)
+		}
+	default:
+		t.Fatal("expected a final push on shutdown")
+	}
+
+	if got := testutil.ToFloat64(p.failuresTotal); got != 0 {
+		t.Fatalf("expected no push failure, got %f", got)
+	}
+}
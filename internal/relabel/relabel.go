@@ -0,0 +1,201 @@
+/*
+Copyright 2020 Red Hat, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package relabel
+
+import (
+	"crypto/md5"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+	"github.com/rhobs/kube-events-exporter/internal/config"
+)
+
+var relabelTarget = regexp.MustCompile(`^(?:(?:[a-zA-Z_]|\$(?:\{\w+\}|\w+))+\w*)+$`)
+
+// Rule is a compiled relabel configuration. It follows the semantics of the
+// Prometheus relabeling.
+type Rule struct {
+	sourceLabels []model.LabelName
+	separator    string
+	regex        *regexp.Regexp
+	modulus      uint64
+	targetLabel  string
+	replacement  string
+	action       config.RelabelAction
+}
+
+// New compiles the given relabel configurations and applies the Prometheus
+// defaults to the unset fields.
+func New(cfgs []config.RelabelConfig) ([]*Rule, error) {
+	rules := make([]*Rule, 0, len(cfgs))
+	for i, cfg := range cfgs {
+		rule, err := newRule(cfg)
+		if err != nil {
+			return nil, errors.Wrapf(err, "relabel config %d", i)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func newRule(cfg config.RelabelConfig) (*Rule, error) {
+	rule := &Rule{
+		separator:   ";",
+		replacement: "$1",
+		targetLabel: cfg.TargetLabel,
+		modulus:     cfg.Modulus,
+		action:      cfg.Action,
+	}
+
+	for _, name := range cfg.SourceLabels {
+		rule.sourceLabels = append(rule.sourceLabels, model.LabelName(name))
+	}
+	if cfg.Separator != nil {
+		rule.separator = *cfg.Separator
+	}
+	if cfg.Replacement != nil {
+		rule.replacement = *cfg.Replacement
+	}
+	if rule.action == "" {
+		rule.action = config.RelabelReplace
+	}
+
+	regex := "(.*)"
+	if cfg.Regex != nil {
+		regex = *cfg.Regex
+	}
+	var err error
+	rule.regex, err = regexp.Compile("^(?:" + regex + ")$")
+	if err != nil {
+		return nil, errors.Wrapf(err, "compile regex %q", regex)
+	}
+
+	switch rule.action {
+	case config.RelabelReplace:
+		if !relabelTarget.MatchString(rule.targetLabel) {
+			return nil, errors.Errorf("%q is invalid target_label for %s action", rule.targetLabel, rule.action)
+		}
+	case config.RelabelLowercase, config.RelabelUppercase:
+		if !model.LabelName(rule.targetLabel).IsValid() {
+			return nil, errors.Errorf("%q is invalid target_label for %s action", rule.targetLabel, rule.action)
+		}
+	case config.RelabelHashMod:
+		if !model.LabelName(rule.targetLabel).IsValid() {
+			return nil, errors.Errorf("%q is invalid target_label for %s action", rule.targetLabel, rule.action)
+		}
+		if rule.modulus == 0 {
+			return nil, errors.Errorf("modulus must be set for %s action", rule.action)
+		}
+	case config.RelabelKeep, config.RelabelDrop, config.RelabelLabelMap,
+		config.RelabelLabelDrop, config.RelabelLabelKeep:
+	default:
+		return nil, errors.Errorf("unknown relabel action %q", rule.action)
+	}
+
+	return rule, nil
+}
+
+// Process applies the rules to a copy of the label set in order. It returns
+// nil if the label set was dropped.
+func Process(labels model.LabelSet, rules ...*Rule) model.LabelSet {
+	labels = labels.Clone()
+	for _, rule := range rules {
+		if !rule.process(labels) {
+			return nil
+		}
+	}
+	return labels
+}
+
+func (r *Rule) process(labels model.LabelSet) bool {
+	values := make([]string, 0, len(r.sourceLabels))
+	for _, name := range r.sourceLabels {
+		values = append(values, string(labels[name]))
+	}
+	val := strings.Join(values, r.separator)
+
+	switch r.action {
+	case config.RelabelDrop:
+		if r.regex.MatchString(val) {
+			return false
+		}
+	case config.RelabelKeep:
+		if !r.regex.MatchString(val) {
+			return false
+		}
+	case config.RelabelReplace:
+		indexes := r.regex.FindStringSubmatchIndex(val)
+		// If there is no match no replacement must take place.
+		if indexes == nil {
+			break
+		}
+		target := model.LabelName(r.regex.ExpandString([]byte{}, r.targetLabel, val, indexes))
+		if !target.IsValid() {
+			delete(labels, model.LabelName(r.targetLabel))
+			break
+		}
+		res := r.regex.ExpandString([]byte{}, r.replacement, val, indexes)
+		if len(res) == 0 {
+			delete(labels, target)
+			break
+		}
+		labels[target] = model.LabelValue(res)
+	case config.RelabelLowercase:
+		labels[model.LabelName(r.targetLabel)] = model.LabelValue(strings.ToLower(val))
+	case config.RelabelUppercase:
+		labels[model.LabelName(r.targetLabel)] = model.LabelValue(strings.ToUpper(val))
+	case config.RelabelHashMod:
+		mod := sum64(md5.Sum([]byte(val))) % r.modulus
+		labels[model.LabelName(r.targetLabel)] = model.LabelValue(fmt.Sprintf("%d", mod))
+	case config.RelabelLabelMap:
+		for name, value := range labels.Clone() {
+			if r.regex.MatchString(string(name)) {
+				res := r.regex.ReplaceAllString(string(name), r.replacement)
+				labels[model.LabelName(res)] = value
+			}
+		}
+	case config.RelabelLabelDrop:
+		for name := range labels {
+			if r.regex.MatchString(string(name)) {
+				delete(labels, name)
+			}
+		}
+	case config.RelabelLabelKeep:
+		for name := range labels {
+			if !r.regex.MatchString(string(name)) {
+				delete(labels, name)
+			}
+		}
+	}
+
+	return true
+}
+
+// sum64 sums the md5 hash to an uint64 the same way Prometheus does.
+func sum64(hash [md5.Size]byte) uint64 {
+	var s uint64
+
+	for i, b := range hash {
+		shift := uint64((md5.Size - 1 - i) * 8)
+
+		s |= uint64(b) << shift
+	}
+	return s
+}
@@ -0,0 +1,198 @@
+/*
+Copyright 2020 Red Hat, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package relabel
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/prometheus/common/model"
+	"github.com/rhobs/kube-events-exporter/internal/config"
+)
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+func TestProcess(t *testing.T) {
+	labels := model.LabelSet{
+		"type":                 "Warning",
+		"involved_object_kind": "Pod",
+		"reason":               "FailedSync-1a2b3c",
+	}
+
+	testCases := []struct {
+		desc     string
+		cfg      config.RelabelConfig
+		expected model.LabelSet
+	}{
+		{
+			desc: "Replace",
+			cfg: config.RelabelConfig{
+				SourceLabels: []string{"reason"},
+				Regex:        stringPtr("(.*)-[0-9a-f]+"),
+				TargetLabel:  "reason",
+			},
+			expected: model.LabelSet{
+				"type":                 "Warning",
+				"involved_object_kind": "Pod",
+				"reason":               "FailedSync",
+			},
+		},
+		{
+			desc: "ReplaceNoMatch",
+			cfg: config.RelabelConfig{
+				SourceLabels: []string{"reason"},
+				Regex:        stringPtr("Back(.*)"),
+				TargetLabel:  "reason",
+			},
+			expected: labels,
+		},
+		{
+			desc: "ReplaceEmpty",
+			cfg: config.RelabelConfig{
+				TargetLabel: "reason",
+				Replacement: stringPtr(""),
+			},
+			expected: model.LabelSet{
+				"type":                 "Warning",
+				"involved_object_kind": "Pod",
+			},
+		},
+		{
+			desc: "Keep",
+			cfg: config.RelabelConfig{
+				SourceLabels: []string{"type"},
+				Regex:        stringPtr("Warning"),
+				Action:       config.RelabelKeep,
+			},
+			expected: labels,
+		},
+		{
+			desc: "Drop",
+			cfg: config.RelabelConfig{
+				SourceLabels: []string{"type", "involved_object_kind"},
+				Regex:        stringPtr("Warning;Pod"),
+				Action:       config.RelabelDrop,
+			},
+			expected: nil,
+		},
+		{
+			desc: "LabelMap",
+			cfg: config.RelabelConfig{
+				Regex:       stringPtr("involved_object_(.*)"),
+				Replacement: stringPtr("object_$1"),
+				Action:      config.RelabelLabelMap,
+			},
+			expected: model.LabelSet{
+				"type":                 "Warning",
+				"involved_object_kind": "Pod",
+				"object_kind":          "Pod",
+				"reason":               "FailedSync-1a2b3c",
+			},
+		},
+		{
+			desc: "LabelDrop",
+			cfg: config.RelabelConfig{
+				Regex:  stringPtr("involved_object_.*"),
+				Action: config.RelabelLabelDrop,
+			},
+			expected: model.LabelSet{
+				"type":   "Warning",
+				"reason": "FailedSync-1a2b3c",
+			},
+		},
+		{
+			desc: "Lowercase",
+			cfg: config.RelabelConfig{
+				SourceLabels: []string{"reason"},
+				TargetLabel:  "reason",
+				Action:       config.RelabelLowercase,
+			},
+			expected: model.LabelSet{
+				"type":                 "Warning",
+				"involved_object_kind": "Pod",
+				"reason":               "failedsync-1a2b3c",
+			},
+		},
+		{
+			desc: "HashMod",
+			cfg: config.RelabelConfig{
+				SourceLabels: []string{"reason"},
+				TargetLabel:  "shard",
+				Modulus:      1,
+				Action:       config.RelabelHashMod,
+			},
+			expected: model.LabelSet{
+				"type":                 "Warning",
+				"involved_object_kind": "Pod",
+				"reason":               "FailedSync-1a2b3c",
+				"shard":                "0",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+			rules, err := New([]config.RelabelConfig{tc.cfg})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := Process(labels, rules...)
+			if !reflect.DeepEqual(got, tc.expected) {
+				t.Fatalf("expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestNewInvalid(t *testing.T) {
+	testCases := []struct {
+		desc string
+		cfg  config.RelabelConfig
+	}{
+		{
+			desc: "Action",
+			cfg:  config.RelabelConfig{Action: "unknown"},
+		},
+		{
+			desc: "Regex",
+			cfg:  config.RelabelConfig{Regex: stringPtr("(")},
+		},
+		{
+			desc: "TargetLabel",
+			cfg:  config.RelabelConfig{TargetLabel: "invalid-label"},
+		},
+		{
+			desc: "Modulus",
+			cfg:  config.RelabelConfig{TargetLabel: "shard", Action: config.RelabelHashMod},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+			_, err := New([]config.RelabelConfig{tc.cfg})
+			if err == nil {
+				t.Fatal("expected relabel config to be invalid")
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+/*
+Copyright 2020 Red Hat, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package remotewrite
+
+import (
+	"math"
+	"sort"
+	"strconv"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/model"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// label, sample and timeSeries mirror the messages of the remote write
+// protocol defined in prometheus/prompb/types.proto.
+type label struct {
+	name  string
+	value string
+}
+
+type sample struct {
+	value     float64
+	timestamp int64
+}
+
+type timeSeries struct {
+	labels  []label
+	samples []sample
+}
+
+// toTimeSeries converts the gathered metric families into remote write time
+// series. External labels are added to the series not already having them,
+// empty labels being dropped.
+// timestamp is used for the samples without an explicit timestamp.
+func toTimeSeries(families []*dto.MetricFamily, externalLabels map[string]string, timestamp int64) []timeSeries {
+	var series []timeSeries
+	for _, family := range families {
+		for _, m := range family.GetMetric() {
+			ts := timestamp
+			if m.TimestampMs != nil {
+				ts = m.GetTimestampMs()
+			}
+			add := func(name string, value float64, extra ...label) {
+				labels := make([]label, 0, len(m.GetLabel())+len(extra)+len(externalLabels)+1)
+				labels = append(labels, label{name: model.MetricNameLabel, value: name})
+				present := make(map[string]struct{}, len(m.GetLabel())+len(extra))
+				for _, l := range m.GetLabel() {
+					// Empty labels are the same as missing labels
+					// and are rejected by Prometheus.
+					if l.GetValue() == "" {
+						continue
+					}
+					labels = append(labels, label{name: l.GetName(), value: l.GetValue()})
+					present[l.GetName()] = struct{}{}
+				}
+				for _, l := range extra {
+					labels = append(labels, l)
+					present[l.name] = struct{}{}
+				}
+				for name, value := range externalLabels {
+					if _, ok := present[name]; !ok && value != "" {
+						labels = append(labels, label{name: name, value: value})
+					}
+				}
+				// The protocol requires the labels to be sorted by name.
+				sort.Slice(labels, func(i, j int) bool {
+					return labels[i].name < labels[j].name
+				})
+				series = append(series, timeSeries{
+					labels:  labels,
+					samples: []sample{{value: value, timestamp: ts}},
+				})
+			}
+
+			name := family.GetName()
+			switch family.GetType() {
+			case dto.MetricType_COUNTER:
+				add(name, m.GetCounter().GetValue())
+			case dto.MetricType_GAUGE:
+				add(name, m.GetGauge().GetValue())
+			case dto.MetricType_UNTYPED:
+				add(name, m.GetUntyped().GetValue())
+			case dto.MetricType_SUMMARY:
+				summary := m.GetSummary()
+				for _, q := range summary.GetQuantile() {
+					add(name, q.GetValue(), label{name: model.QuantileLabel, value: formatFloat(q.GetQuantile())})
+				}
+				add(name+"_sum", summary.GetSampleSum())
+				add(name+"_count", float64(summary.GetSampleCount()))
+			case dto.MetricType_HISTOGRAM:
+				histogram := m.GetHistogram()
+				infSeen := false
+				for _, b := range histogram.GetBucket() {
+					if math.IsInf(b.GetUpperBound(), +1) {
+						infSeen = true
+					}
+					add(name+"_bucket", float64(b.GetCumulativeCount()), label{name: model.BucketLabel, value: formatFloat(b.GetUpperBound())})
+				}
+				if !infSeen {
+					add(name+"_bucket", float64(histogram.GetSampleCount()), label{name: model.BucketLabel, value: "+Inf"})
+				}
+				add(name+"_sum", histogram.GetSampleSum())
+				add(name+"_count", float64(histogram.GetSampleCount()))
+			}
+		}
+	}
+	return series
+}
+
+func formatFloat(f float64) string {
+	if math.IsInf(f, +1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// marshalWriteRequest encodes the time series as a WriteRequest protobuf
+// message.
+func marshalWriteRequest(series []timeSeries) []byte {
+	var b []byte
+	for _, ts := range series {
+		b = protowire.AppendTag(b, 1, protowire.BytesType)
+		b = protowire.AppendBytes(b, marshalTimeSeries(ts))
+	}
+	return b
+}
+
+func marshalTimeSeries(ts timeSeries) []byte {
+	var b []byte
+	for _, l := range ts.labels {
+		var lb []byte
+		lb = protowire.AppendTag(lb, 1, protowire.BytesType)
+		lb = protowire.AppendString(lb, l.name)
+		lb = protowire.AppendTag(lb, 2, protowire.BytesType)
+		lb = protowire.AppendString(lb, l.value)
+
+		b = protowire.AppendTag(b, 1, protowire.BytesType)
+		b = protowire.AppendBytes(b, lb)
+	}
+	for _, s := range ts.samples {
+		var sb []byte
+		sb = protowire.AppendTag(sb, 1, protowire.Fixed64Type)
+		sb = protowire.AppendFixed64(sb, math.Float64bits(s.value))
+		sb = protowire.AppendTag(sb, 2, protowire.VarintType)
+		sb = protowire.AppendVarint(sb, uint64(s.timestamp))
+
+		b = protowire.AppendTag(b, 2, protowire.BytesType)
+		b = protowire.AppendBytes(b, sb)
+	}
+	return b
+}
@@ -0,0 +1,236 @@
+/*
+Copyright 2020 Red Hat, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package remotewrite periodically pushes metrics with the Prometheus remote
+// write protocol.
+package remotewrite
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rhobs/kube-events-exporter/internal/config"
+	"github.com/rhobs/kube-events-exporter/internal/httpclient"
+	"github.com/rhobs/kube-events-exporter/internal/version"
+
+	"k8s.io/klog"
+)
+
+// Writer periodically gathers metrics and pushes them to a remote write
+// endpoint. Pending requests are kept in a bounded in-memory queue and retried
+// with an exponential backoff.
+type Writer struct {
+	cfg      *config.RemoteWriteConfig
+	client   *http.Client
+	gatherer prometheus.Gatherer
+	requests chan *request
+
+	samplesSent    prometheus.Counter
+	samplesFailed  prometheus.Counter
+	samplesDropped prometheus.Counter
+	retriesTotal   prometheus.Counter
+	queueLength    prometheus.Gauge
+}
+
+type request struct {
+	body    []byte
+	samples int
+}
+
+// recoverableError is an error after which the request can be retried.
+type recoverableError struct {
+	error
+}
+
+// New returns a Writer pushing the metrics gathered from gatherer. Its own
+// metrics are registered in exporterRegistry.
+func New(cfg *config.RemoteWriteConfig, gatherer prometheus.Gatherer, exporterRegistry *prometheus.Registry) (*Writer, error) {
+	client, err := httpclient.New(cfg.HTTPClientConfig)
+	if err != nil {
+		return nil, errors.Wrap(err, "create HTTP client")
+	}
+
+	w := &Writer{
+		cfg:      cfg,
+		client:   client,
+		gatherer: gatherer,
+		requests: make(chan *request, cfg.Queue.Capacity),
+		samplesSent: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "kube_events_exporter_remote_write_samples_sent_total",
+			Help: "Number of samples successfully pushed to the remote write endpoint",
+		}),
+		samplesFailed: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "kube_events_exporter_remote_write_samples_failed_total",
+			Help: "Number of samples that failed to be pushed to the remote write endpoint",
+		}),
+		samplesDropped: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "kube_events_exporter_remote_write_samples_dropped_total",
+			Help: "Number of samples dropped because the remote write queue was full",
+		}),
+		retriesTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "kube_events_exporter_remote_write_retries_total",
+			Help: "Number of times pushing to the remote write endpoint was retried",
+		}),
+		queueLength: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "kube_events_exporter_remote_write_queue_length",
+			Help: "Number of requests waiting in the remote write queue",
+		}),
+	}
+	exporterRegistry.MustRegister(
+		w.samplesSent,
+		w.samplesFailed,
+		w.samplesDropped,
+		w.retriesTotal,
+		w.queueLength,
+	)
+
+	return w, nil
+}
+
+// Run gathers the metrics at the configured interval and pushes them until
+// stopCh is closed.
+func (w *Writer) Run(stopCh <-chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-stopCh
+		cancel()
+	}()
+
+	go w.sendLoop(ctx)
+
+	ticker := time.NewTicker(time.Duration(w.cfg.Interval))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := w.enqueue(time.Now())
+			if err != nil {
+				klog.Errorf("failed to gather metrics for remote write: %v", err)
+			}
+		}
+	}
+}
+
+// enqueue gathers the metrics and queues the resulting request. The oldest
+// pending request is dropped if the queue is full.
+func (w *Writer) enqueue(now time.Time) error {
+	families, err := w.gatherer.Gather()
+	if err != nil {
+		return errors.Wrap(err, "gather metrics")
+	}
+
+	series := toTimeSeries(families, w.cfg.ExternalLabels, now.UnixNano()/int64(time.Millisecond))
+	req := &request{
+		body:    snappy.Encode(nil, marshalWriteRequest(series)),
+		samples: len(series),
+	}
+
+	defer w.queueLength.Set(float64(len(w.requests)))
+	for {
+		select {
+		case w.requests <- req:
+			return nil
+		default:
+		}
+
+		select {
+		case dropped := <-w.requests:
+			w.samplesDropped.Add(float64(dropped.samples))
+		default:
+		}
+	}
+}
+
+func (w *Writer) sendLoop(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case req := <-w.requests:
+			w.queueLength.Set(float64(len(w.requests)))
+			w.sendWithRetries(ctx, req)
+		}
+	}
+}
+
+func (w *Writer) sendWithRetries(ctx context.Context, req *request) {
+	backoff := time.Duration(w.cfg.Queue.MinBackoff)
+	for retry := 0; ; retry++ {
+		err := w.send(ctx, req)
+		if err == nil {
+			w.samplesSent.Add(float64(req.samples))
+			return
+		}
+
+		if _, ok := err.(recoverableError); !ok || retry >= *w.cfg.Queue.MaxRetries {
+			klog.Errorf("failed to push %d samples to %s: %v", req.samples, w.cfg.URL, err)
+			w.samplesFailed.Add(float64(req.samples))
+			return
+		}
+
+		w.retriesTotal.Inc()
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			w.samplesFailed.Add(float64(req.samples))
+			return
+		}
+
+		backoff *= 2
+		if backoff > time.Duration(w.cfg.Queue.MaxBackoff) {
+			backoff = time.Duration(w.cfg.Queue.MaxBackoff)
+		}
+	}
+}
+
+func (w *Writer) send(ctx context.Context, req *request) error {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, w.cfg.URL, bytes.NewReader(req.body))
+	if err != nil {
+		return errors.Wrap(err, "create request")
+	}
+	httpReq.Header.Set("Content-Encoding", "snappy")
+	httpReq.Header.Set("Content-Type", "application/x-protobuf")
+	httpReq.Header.Set("User-Agent", "kube-events-exporter/"+version.GetVersion())
+	httpReq.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+
+	resp, err := w.client.Do(httpReq)
+	if err != nil {
+		return recoverableError{errors.Wrapf(err, "push to %s", w.cfg.URL)}
+	}
+	defer resp.Body.Close()
+	// Drain the body to reuse the connection.
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode/100 == 2 {
+		return nil
+	}
+	err = errors.Errorf("push to %s: unexpected status %s", w.cfg.URL, resp.Status)
+	// As in Prometheus, only server errors and rate limiting are retried.
+	if resp.StatusCode/100 == 5 || resp.StatusCode == http.StatusTooManyRequests {
+		return recoverableError{err}
+	}
+	return err
+}
@@ -0,0 +1,294 @@
+/*
+Copyright 2020 Red Hat, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package remotewrite
+
+import (
+	"context"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rhobs/kube-events-exporter/internal/config"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestWriter(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		statuses []int
+		sent     float64
+		failed   float64
+	}{
+		{
+			desc:     "Success",
+			statuses: []int{http.StatusNoContent},
+			sent:     1,
+		},
+		{
+			desc:     "RetryServerError",
+			statuses: []int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusOK},
+			sent:     1,
+		},
+		{
+			desc:     "ClientError",
+			statuses: []int{http.StatusBadRequest, http.StatusOK},
+			failed:   1,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+			var attempts int32
+			bodies := make(chan []byte, len(tc.statuses))
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if got := r.Header.Get("Content-Encoding"); got != "snappy" {
+					t.Errorf("expected snappy encoding, got %q", got)
+				}
+				if got := r.Header.Get("X-Prometheus-Remote-Write-Version"); got != "0.1.0" {
+					t.Errorf("expected remote write version 0.1.0, got %q", got)
+				}
+				body, err := ioutil.ReadAll(r.Body)
+				if err != nil {
+					t.Error(err)
+				}
+				bodies <- body
+				w.WriteHeader(tc.statuses[atomic.AddInt32(&attempts, 1)-1])
+			}))
+			defer server.Close()
+
+			registry := prometheus.NewRegistry()
+			eventsTotal := prometheus.NewCounterVec(prometheus.CounterOpts{
+				Name: "kube_events_total",
+				Help: "Count of all Kubernetes Events",
+			}, []string{"type", "reason"})
+			registry.MustRegister(eventsTotal)
+			eventsTotal.WithLabelValues("Warning", "BackOff").Add(3)
+
+			maxRetries := 3
+			w, err := New(&config.RemoteWriteConfig{
+				URL:            server.URL,
+				ExternalLabels: map[string]string{"cluster": "edge-1", "type": "ignored"},
+				Queue: config.RemoteWriteQueueConfig{
+					Capacity:   1,
+					MaxRetries: &maxRetries,
+					MinBackoff: config.Duration(time.Millisecond),
+					MaxBackoff: config.Duration(time.Millisecond),
+				},
+			}, registry, prometheus.NewRegistry())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			now := time.Unix(1597226400, 0)
+			err = w.enqueue(now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			w.sendWithRetries(context.Background(), <-w.requests)
+
+			if got := testutil.ToFloat64(w.samplesSent); got != tc.sent {
+				t.Fatalf("expected %f sent samples, got %f", tc.sent, got)
+			}
+			if got := testutil.ToFloat64(w.samplesFailed); got != tc.failed {
+				t.Fatalf("expected %f failed samples, got %f", tc.failed, got)
+			}
+
+			body, err := snappy.Decode(nil, <-bodies)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected := []timeSeries{{
+				labels: []label{
+					{name: "__name__", value: "kube_events_total"},
+					{name: "cluster", value: "edge-1"},
+					{name: "reason", value: "BackOff"},
+					{name: "type", value: "Warning"},
+				},
+				samples: []sample{{value: 3, timestamp: 1597226400000}},
+			}}
+			if got := unmarshalWriteRequest(t, body); !reflect.DeepEqual(got, expected) {
+				t.Fatalf("expected time series %v, got %v", expected, got)
+			}
+		})
+	}
+}
+
+func TestEnqueueDropOldest(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(prometheus.NewGauge(prometheus.GaugeOpts{Name: "up"}))
+
+	w, err := New(&config.RemoteWriteConfig{
+		URL:   "http://localhost",
+		Queue: config.RemoteWriteQueueConfig{Capacity: 1},
+	}, registry, prometheus.NewRegistry())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		err := w.enqueue(time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := testutil.ToFloat64(w.samplesDropped); got != 2 {
+		t.Fatalf("expected 2 dropped samples, got %f", got)
+	}
+}
+
+// unmarshalWriteRequest decodes the time series of a WriteRequest protobuf
+// message.
+func unmarshalWriteRequest(t *testing.T, b []byte) []timeSeries {
+	t.Helper()
+	var series []timeSeries
+	forEachField(t, b, func(num protowire.Number, v []byte, _ uint64) {
+		ts := timeSeries{}
+		forEachField(t, v, func(num protowire.Number, v []byte, _ uint64) {
+			switch num {
+			case 1:
+				l := label{}
+				forEachField(t, v, func(num protowire.Number, v []byte, _ uint64) {
+					if num == 1 {
+						l.name = string(v)
+					} else {
+						l.value = string(v)
+					}
+				})
+				ts.labels = append(ts.labels, l)
+			case 2:
+				s := sample{}
+				forEachField(t, v, func(num protowire.Number, _ []byte, n uint64) {
+					if num == 1 {
+						s.value = math.Float64frombits(n)
+					} else {
+						s.timestamp = int64(n)
+					}
+				})
+				ts.samples = append(ts.samples, s)
+			}
+		})
+		series = append(series, ts)
+	})
+	return series
+}
+
+func forEachField(t *testing.T, b []byte, f func(num protowire.Number, v []byte, n uint64)) {
+	t.Helper()
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			t.Fatal(protowire.ParseError(n))
+		}
+		b = b[n:]
+
+		switch typ {
+		case protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				t.Fatal(protowire.ParseError(n))
+			}
+			f(num, v, 0)
+			b = b[n:]
+		case protowire.Fixed64Type:
+			v, n := protowire.ConsumeFixed64(b)
+			if n < 0 {
+				t.Fatal(protowire.ParseError(n))
+			}
+			f(num, nil, v)
+			b = b[n:]
+		case protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			if n < 0 {
+				t.Fatal(protowire.ParseError(n))
+			}
+			f(num, nil, v)
+			b = b[n:]
+		default:
+			t.Fatalf("unexpected wire type %d", typ)
+		}
+	}
+}
+
+func TestToTimeSeriesHistogram(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	histogram := prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "duration_seconds",
+		Buckets: []float64{0.5},
+	})
+	registry.MustRegister(histogram)
+	histogram.Observe(0.1)
+	histogram.Observe(1)
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(map[string]float64)
+	for _, ts := range toTimeSeries(families, nil, 0) {
+		name := ""
+		for _, l := range ts.labels {
+			name += l.name + "=" + l.value + ","
+		}
+		got[name] = ts.samples[0].value
+	}
+
+	expected := map[string]float64{
+		"__name__=duration_seconds_bucket,le=0.5,":  1,
+		"__name__=duration_seconds_bucket,le=+Inf,": 2,
+		"__name__=duration_seconds_sum,":            1.1,
+		"__name__=duration_seconds_count,":          2,
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected series %v, got %v", expected, got)
+	}
+}
+
+func TestToTimeSeriesEmptyLabels(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	counter := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kube_events_total",
+	}, []string{"cluster", "reason"})
+	registry.MustRegister(counter)
+	counter.WithLabelValues("", "BackOff").Inc()
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	series := toTimeSeries(families, map[string]string{"cluster": "east", "replica": ""}, 0)
+	expected := []label{
+		{name: "__name__", value: "kube_events_total"},
+		{name: "cluster", value: "east"},
+		{name: "reason", value: "BackOff"},
+	}
+	if len(series) != 1 || !reflect.DeepEqual(series[0].labels, expected) {
+		t.Fatalf("expected series with labels %v, got %v", expected, series)
+	}
+}
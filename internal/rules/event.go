@@ -0,0 +1,175 @@
+/*
+Copyright 2020 Red Hat, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rules
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/checker"
+	"github.com/google/cel-go/interpreter/functions"
+	"github.com/pkg/errors"
+
+	exprpb "google.golang.org/genproto/googleapis/api/expr/v1alpha1"
+	v1 "k8s.io/api/core/v1"
+)
+
+var objectType = cel.MapType(cel.StringType, cel.DynType)
+
+// newEnv declares the Event fields that can be used inside expressions. They
+// are named after the JSON representation of the Event.
+func newEnv() (*cel.Env, error) {
+	return cel.NewCustomEnv(
+		cel.Lib(stdLibrary{}),
+		cel.Variable("type", cel.StringType),
+		cel.Variable("reason", cel.StringType),
+		cel.Variable("message", cel.StringType),
+		cel.Variable("action", cel.StringType),
+		cel.Variable("count", cel.IntType),
+		cel.Variable("reportingController", cel.StringType),
+		cel.Variable("reportingInstance", cel.StringType),
+		cel.Variable("firstTimestamp", cel.TimestampType),
+		cel.Variable("lastTimestamp", cel.TimestampType),
+		cel.Variable("eventTime", cel.TimestampType),
+		cel.Variable("metadata", objectType),
+		cel.Variable("involvedObject", objectType),
+		cel.Variable("related", objectType),
+		cel.Variable("source", objectType),
+		cel.Variable("series", objectType),
+	)
+}
+
+// stdLibrary is the CEL standard library without the `type` identifier which
+// would otherwise shadow the Event type field.
+type stdLibrary struct{}
+
+func (stdLibrary) CompileOptions() []cel.EnvOption {
+	var declarations []*exprpb.Decl
+	for _, decl := range checker.StandardDeclarations() {
+		if decl.GetIdent() != nil && decl.GetName() == "type" {
+			continue
+		}
+		declarations = append(declarations, decl)
+	}
+
+	return []cel.EnvOption{
+		cel.Declarations(declarations...),
+		cel.Macros(cel.StandardMacros...),
+	}
+}
+
+func (stdLibrary) ProgramOptions() []cel.ProgramOption {
+	return []cel.ProgramOption{
+		cel.Functions(functions.StandardOverloads()...),
+	}
+}
+
+func eventActivation(ev *v1.Event) map[string]interface{} {
+	series := map[string]interface{}{
+		"count":            int64(0),
+		"lastObservedTime": ev.EventTime.Time,
+	}
+	if ev.Series != nil {
+		series["count"] = int64(ev.Series.Count)
+		series["lastObservedTime"] = ev.Series.LastObservedTime.Time
+	}
+
+	related := objectReference(&v1.ObjectReference{})
+	if ev.Related != nil {
+		related = objectReference(ev.Related)
+	}
+
+	return map[string]interface{}{
+		"type":                ev.Type,
+		"reason":              ev.Reason,
+		"message":             ev.Message,
+		"action":              ev.Action,
+		"count":               int64(ev.Count),
+		"reportingController": ev.ReportingController,
+		"reportingInstance":   ev.ReportingInstance,
+		"firstTimestamp":      ev.FirstTimestamp.Time,
+		"lastTimestamp":       ev.LastTimestamp.Time,
+		"eventTime":           ev.EventTime.Time,
+		"metadata": map[string]interface{}{
+			"name":        ev.Name,
+			"namespace":   ev.Namespace,
+			"uid":         string(ev.UID),
+			"labels":      stringMap(ev.Labels),
+			"annotations": stringMap(ev.Annotations),
+		},
+		"involvedObject": objectReference(&ev.InvolvedObject),
+		"related":        related,
+		"source": map[string]interface{}{
+			"component": ev.Source.Component,
+			"host":      ev.Source.Host,
+		},
+		"series": series,
+	}
+}
+
+func objectReference(ref *v1.ObjectReference) map[string]interface{} {
+	return map[string]interface{}{
+		"kind":            ref.Kind,
+		"namespace":       ref.Namespace,
+		"name":            ref.Name,
+		"uid":             string(ref.UID),
+		"apiVersion":      ref.APIVersion,
+		"resourceVersion": ref.ResourceVersion,
+		"fieldPath":       ref.FieldPath,
+	}
+}
+
+func stringMap(m map[string]string) map[string]string {
+	if m == nil {
+		return map[string]string{}
+	}
+	return m
+}
+
+// EventField returns the value of the Event field at the given dot separated
+// path, e.g. involvedObject.kind. Fields are named the same way as inside
+// expressions.
+func EventField(ev *v1.Event, path string) (string, error) {
+	var cur interface{} = eventActivation(ev)
+	for _, key := range strings.Split(path, ".") {
+		switch obj := cur.(type) {
+		case map[string]interface{}:
+			val, ok := obj[key]
+			if !ok {
+				return "", errors.Errorf("unknown field %q in %s", key, path)
+			}
+			cur = val
+		case map[string]string:
+			cur = obj[key]
+		default:
+			return "", errors.Errorf("field %q in %s is not an object", key, path)
+		}
+	}
+
+	switch val := cur.(type) {
+	case string:
+		return val, nil
+	case int64:
+		return strconv.FormatInt(val, 10), nil
+	case time.Time:
+		return val.UTC().Format(time.RFC3339), nil
+	default:
+		return "", errors.Errorf("field %s is not a scalar", path)
+	}
+}
@@ -0,0 +1,141 @@
+/*
+Copyright 2020 Red Hat, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rules
+
+import (
+	"strings"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/checker"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rhobs/kube-events-exporter/internal/config"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog"
+)
+
+// Rule is a compiled CEL expression selecting Events.
+type Rule struct {
+	Name       string
+	Expression string
+	Cost       checker.CostEstimate
+
+	program     cel.Program
+	errorsTotal prometheus.Counter
+}
+
+// RuleSet holds compiled rules indexed by name.
+type RuleSet struct {
+	rules map[string]*Rule
+}
+
+// NewRuleSet compiles the given rules and registers their evaluation metrics
+// inside exporterRegistry. Compilation fails if an expression is invalid or
+// doesn't evaluate to a boolean.
+func NewRuleSet(cfgs []config.RuleConfig, exporterRegistry *prometheus.Registry) (*RuleSet, error) {
+	env, err := newEnv()
+	if err != nil {
+		return nil, errors.Wrap(err, "create CEL environment")
+	}
+
+	errorsTotal := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kube_events_exporter_rule_evaluation_errors_total",
+		Help: "Number of times a rule failed to be evaluated against an Event.",
+	}, []string{"rule"})
+	exporterRegistry.MustRegister(errorsTotal)
+
+	set := &RuleSet{rules: make(map[string]*Rule, len(cfgs))}
+	for _, cfg := range cfgs {
+		rule, err := compile(env, cfg)
+		if err != nil {
+			return nil, errors.Wrapf(err, "compile rule %q", cfg.Name)
+		}
+		rule.errorsTotal = errorsTotal.WithLabelValues(cfg.Name)
+		set.rules[cfg.Name] = rule
+
+		klog.Infof("compiled rule %q with estimated cost [%d, %d]: %s",
+			rule.Name, rule.Cost.Min, rule.Cost.Max, rule.Expression)
+	}
+
+	return set, nil
+}
+
+// Get returns the rule with the given name or nil if it doesn't exist.
+func (s *RuleSet) Get(name string) *Rule {
+	return s.rules[name]
+}
+
+// Match evaluates the rule against an Event. Evaluation errors are counted
+// and the Event is considered as not matching. Accessing a key missing from a
+// map, such as an unset label, isn't an error since most Events don't have
+// every optional field.
+func (r *Rule) Match(ev *v1.Event) bool {
+	out, _, err := r.program.Eval(eventActivation(ev))
+	if err != nil {
+		// cel-go doesn't export the error type of missing keys.
+		if strings.HasPrefix(err.Error(), "no such key") {
+			return false
+		}
+		r.errorsTotal.Inc()
+		klog.V(4).Infof("failed to evaluate rule %q: %v", r.Name, err)
+		return false
+	}
+
+	match, ok := out.Value().(bool)
+	return ok && match
+}
+
+func compile(env *cel.Env, cfg config.RuleConfig) (*Rule, error) {
+	ast, issues := env.Compile(cfg.Expression)
+	if issues != nil && issues.Err() != nil {
+		return nil, issues.Err()
+	}
+
+	if ast.OutputType() != cel.BoolType {
+		return nil, errors.Errorf("expression must evaluate to bool, got %s", ast.OutputType())
+	}
+
+	cost, err := env.EstimateCost(ast, costEstimator{})
+	if err != nil {
+		return nil, errors.Wrap(err, "estimate cost")
+	}
+
+	program, err := env.Program(ast)
+	if err != nil {
+		return nil, errors.Wrap(err, "create program")
+	}
+
+	return &Rule{
+		Name:       cfg.Name,
+		Expression: cfg.Expression,
+		Cost:       cost,
+		program:    program,
+	}, nil
+}
+
+// costEstimator relies on the default CEL estimations since the size of Event
+// fields isn't bounded.
+type costEstimator struct{}
+
+func (costEstimator) EstimateSize(checker.AstNode) *checker.SizeEstimate {
+	return nil
+}
+
+func (costEstimator) EstimateCallCost(string, string, *checker.AstNode, []checker.AstNode) *checker.CallEstimate {
+	return nil
+}
@@ -0,0 +1,145 @@
+/*
+Copyright 2020 Red Hat, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rules
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rhobs/kube-events-exporter/internal/config"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestNewRuleSet(t *testing.T) {
+	testCases := []struct {
+		desc       string
+		expression string
+		valid      bool
+	}{
+		{
+			desc:       "Valid",
+			expression: `type == "Warning" && involvedObject.kind in ["Pod", "Node"]`,
+			valid:      true,
+		},
+		{
+			desc:       "Syntax",
+			expression: `type ==`,
+			valid:      false,
+		},
+		{
+			desc:       "UnknownField",
+			expression: `unknown == "Warning"`,
+			valid:      false,
+		},
+		{
+			desc:       "NotBool",
+			expression: `reason`,
+			valid:      false,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+			cfgs := []config.RuleConfig{{Name: tc.desc, Expression: tc.expression}}
+			_, err := NewRuleSet(cfgs, prometheus.NewRegistry())
+			if tc.valid && err != nil {
+				t.Fatalf("expected rule to compile, got %v", err)
+			}
+			if !tc.valid && err == nil {
+				t.Fatal("expected rule compilation to fail")
+			}
+		})
+	}
+}
+
+func TestRuleMatch(t *testing.T) {
+	ev := &v1.Event{
+		Type:           "Warning",
+		Reason:         "BackOff",
+		Message:        "Back-off restarting failed container",
+		Count:          3,
+		InvolvedObject: v1.ObjectReference{Kind: "Pod", Namespace: "default"},
+		Source:         v1.EventSource{Component: "kubelet"},
+	}
+
+	testCases := []struct {
+		desc       string
+		expression string
+		expected   bool
+		errors     float64
+	}{
+		{
+			desc:       "Type",
+			expression: `type == "Warning"`,
+			expected:   true,
+		},
+		{
+			desc:       "InvolvedObjectKind",
+			expression: `involvedObject.kind in ["Pod", "Node"]`,
+			expected:   true,
+		},
+		{
+			desc:       "ReasonPrefix",
+			expression: `type == "Warning" && !(reason.startsWith("Back"))`,
+			expected:   false,
+		},
+		{
+			desc:       "Count",
+			expression: `count > 1 && source.component == "kubelet"`,
+			expected:   true,
+		},
+		{
+			desc:       "MissingSeries",
+			expression: `series.count > 0`,
+			expected:   false,
+		},
+		{
+			desc:       "MissingLabel",
+			expression: `metadata.labels["app"] == "foo"`,
+			expected:   false,
+		},
+		{
+			desc:       "EvaluationError",
+			expression: `int(reason) > 0`,
+			expected:   false,
+			errors:     1,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+			cfgs := []config.RuleConfig{{Name: tc.desc, Expression: tc.expression}}
+			set, err := NewRuleSet(cfgs, prometheus.NewRegistry())
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := set.Get(tc.desc).Match(ev)
+			if got != tc.expected {
+				t.Fatalf("expected %t, got %t", tc.expected, got)
+			}
+			if errs := testutil.ToFloat64(set.Get(tc.desc).errorsTotal); errs != tc.errors {
+				t.Fatalf("expected %f evaluation errors, got %f", tc.errors, errs)
+			}
+		})
+	}
+}
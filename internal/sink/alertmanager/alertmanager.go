@@ -0,0 +1,191 @@
+/*
+Copyright 2020 Red Hat, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package alertmanager implements a sink posting Events as alerts to the
+// Alertmanager v2 API.
+package alertmanager
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+	"github.com/rhobs/kube-events-exporter/internal/config"
+	"github.com/rhobs/kube-events-exporter/internal/httpclient"
+	"github.com/rhobs/kube-events-exporter/internal/sink"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+const alertsPath = "/api/v2/alerts"
+
+// Alertmanager is a sink.BatchSink posting Events as alerts to Alertmanager.
+// The alert labels only depend on the involved object and the reason of the
+// Event so that repeated occurrences refresh the same alert.
+type Alertmanager struct {
+	url            string
+	client         *http.Client
+	cluster        string
+	alertName      string
+	resolveTimeout time.Duration
+	labels         model.LabelSet
+	annotations    model.LabelSet
+
+	// now is replaced in tests.
+	now func() time.Time
+}
+
+// Alert is an alert as expected by the Alertmanager v2 API.
+type Alert struct {
+	Labels      model.LabelSet `json:"labels"`
+	Annotations model.LabelSet `json:"annotations"`
+	StartsAt    time.Time      `json:"startsAt"`
+	EndsAt      time.Time      `json:"endsAt"`
+}
+
+// New returns an Alertmanager sink configured from cfg. The alerts are labeled
+// with the cluster of the Events unless it is empty.
+func New(cfg *config.AlertmanagerConfig, cluster string) (*Alertmanager, error) {
+	client, err := httpclient.New(cfg.HTTPClientConfig)
+	if err != nil {
+		return nil, errors.Wrap(err, "create HTTP client")
+	}
+
+	labels, err := toLabelSet(cfg.Labels)
+	if err != nil {
+		return nil, errors.Wrap(err, "labels")
+	}
+	annotations, err := toLabelSet(cfg.Annotations)
+	if err != nil {
+		return nil, errors.Wrap(err, "annotations")
+	}
+
+	return &Alertmanager{
+		url:            strings.TrimSuffix(cfg.URL, "/") + alertsPath,
+		client:         client,
+		cluster:        cluster,
+		alertName:      cfg.AlertName,
+		resolveTimeout: time.Duration(cfg.ResolveTimeout),
+		labels:         labels,
+		annotations:    annotations,
+		now:            time.Now,
+	}, nil
+}
+
+// OnEvent implements the sink.Sink interface.
+func (a *Alertmanager) OnEvent(ctx context.Context, ev *v1.Event, delta float64) error {
+	return a.OnEvents(ctx, []sink.Event{{Event: ev, Delta: delta}})
+}
+
+// OnEvents implements the sink.BatchSink interface. Events resulting in the
+// same alert are merged before being posted in a single request.
+func (a *Alertmanager) OnEvents(ctx context.Context, events []sink.Event) error {
+	alerts := make([]*Alert, 0, len(events))
+	byFingerprint := make(map[model.Fingerprint]*Alert, len(events))
+	for _, ev := range events {
+		alert := a.newAlert(ev.Event)
+		fingerprint := alert.Labels.Fingerprint()
+		if existing, ok := byFingerprint[fingerprint]; ok {
+			if alert.EndsAt.After(existing.EndsAt) {
+				*existing = *alert
+			}
+			continue
+		}
+		byFingerprint[fingerprint] = alert
+		alerts = append(alerts, alert)
+	}
+
+	body, err := json.Marshal(alerts)
+	if err != nil {
+		return errors.Wrap(err, "marshal alerts")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.url, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "create request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "post alerts to %s", a.url)
+	}
+	defer resp.Body.Close()
+	// Drain the body to reuse the connection.
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode/100 != 2 {
+		return errors.Errorf("post alerts to %s: unexpected status %s", a.url, resp.Status)
+	}
+	return nil
+}
+
+func (a *Alertmanager) newAlert(ev *v1.Event) *Alert {
+	labels := model.LabelSet{
+		model.AlertNameLabel:        model.LabelValue(a.alertName),
+		"type":                      model.LabelValue(ev.Type),
+		"involved_object_namespace": model.LabelValue(ev.InvolvedObject.Namespace),
+		"involved_object_kind":      model.LabelValue(ev.InvolvedObject.Kind),
+		"involved_object_name":      model.LabelValue(ev.InvolvedObject.Name),
+		"reason":                    model.LabelValue(ev.Reason),
+	}
+	if a.cluster != "" {
+		labels["cluster"] = model.LabelValue(a.cluster)
+	}
+	labels = labels.Merge(a.labels)
+
+	annotations := model.LabelSet{
+		"message": model.LabelValue(ev.Message),
+	}
+	annotations = annotations.Merge(a.annotations)
+
+	lastSeen := sink.LastSeen(ev)
+	if lastSeen.IsZero() {
+		lastSeen = a.now()
+	}
+	startsAt := ev.FirstTimestamp.Time
+	if startsAt.IsZero() {
+		startsAt = ev.EventTime.Time
+	}
+	if startsAt.IsZero() || startsAt.After(lastSeen) {
+		startsAt = lastSeen
+	}
+
+	return &Alert{
+		Labels:      labels,
+		Annotations: annotations,
+		StartsAt:    startsAt,
+		EndsAt:      lastSeen.Add(a.resolveTimeout),
+	}
+}
+
+func toLabelSet(m map[string]string) (model.LabelSet, error) {
+	labels := make(model.LabelSet, len(m))
+	for name, value := range m {
+		if !model.LabelName(name).IsValid() {
+			return nil, errors.Errorf("invalid label name %q", name)
+		}
+		labels[model.LabelName(name)] = model.LabelValue(value)
+	}
+	return labels, nil
+}
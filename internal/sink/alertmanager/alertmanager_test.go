@@ -0,0 +1,189 @@
+/*
+Copyright 2020 Red Hat, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package alertmanager
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/rhobs/kube-events-exporter/internal/config"
+	"github.com/rhobs/kube-events-exporter/internal/sink"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestAlertmanager(t *testing.T) {
+	first := time.Date(2020, 8, 12, 10, 0, 0, 0, time.UTC)
+	last := first.Add(10 * time.Minute)
+	now := first.Add(time.Hour)
+
+	newEvent := func(name string, count int32, lastTimestamp time.Time) *v1.Event {
+		return &v1.Event{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			InvolvedObject: v1.ObjectReference{
+				Kind:      "Pod",
+				Namespace: "default",
+				Name:      "nginx",
+			},
+			Reason:         "BackOff",
+			Message:        "Back-off restarting failed container",
+			Type:           v1.EventTypeWarning,
+			Count:          count,
+			FirstTimestamp: metav1.NewTime(first),
+			LastTimestamp:  metav1.NewTime(lastTimestamp),
+		}
+	}
+
+	expectedLabels := model.LabelSet{
+		"alertname":                 "KubernetesEvent",
+		"severity":                  "warning",
+		"type":                      "Warning",
+		"involved_object_namespace": "default",
+		"involved_object_kind":      "Pod",
+		"involved_object_name":      "nginx",
+		"reason":                    "BackOff",
+	}
+	expectedClusterLabels := expectedLabels.Clone()
+	expectedClusterLabels["cluster"] = "prod-eu"
+
+	expectedAnnotations := model.LabelSet{
+		"message": "Back-off restarting failed container",
+		"runbook": "https://example.com/runbook",
+	}
+
+	testCases := []struct {
+		desc     string
+		cluster  string
+		events   []sink.Event
+		expected []Alert
+	}{
+		{
+			desc: "Event",
+			events: []sink.Event{
+				{Event: newEvent("nginx.1", 1, first), Delta: 1},
+			},
+			expected: []Alert{{
+				Labels:      expectedLabels,
+				Annotations: expectedAnnotations,
+				StartsAt:    first,
+				EndsAt:      first.Add(5 * time.Minute),
+			}},
+		},
+		{
+			desc: "SeriesUpdates",
+			events: []sink.Event{
+				{Event: newEvent("nginx.1", 1, first), Delta: 1},
+				{Event: newEvent("nginx.1", 5, last), Delta: 4},
+			},
+			expected: []Alert{{
+				Labels:      expectedLabels,
+				Annotations: expectedAnnotations,
+				StartsAt:    first,
+				EndsAt:      last.Add(5 * time.Minute),
+			}},
+		},
+		{
+			desc:    "Cluster",
+			cluster: "prod-eu",
+			events: []sink.Event{
+				{Event: newEvent("nginx.1", 1, first), Delta: 1},
+			},
+			expected: []Alert{{
+				Labels:      expectedClusterLabels,
+				Annotations: expectedAnnotations,
+				StartsAt:    first,
+				EndsAt:      first.Add(5 * time.Minute),
+			}},
+		},
+		{
+			desc: "NoTimestamp",
+			events: []sink.Event{
+				{Event: newEvent("nginx.1", 1, time.Time{}), Delta: 1},
+			},
+			expected: []Alert{{
+				Labels:      expectedLabels,
+				Annotations: expectedAnnotations,
+				StartsAt:    first,
+				EndsAt:      now.Add(5 * time.Minute),
+			}},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+			received := make(chan []Alert, 1)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != alertsPath {
+					t.Errorf("expected request to %s, got %s", alertsPath, r.URL.Path)
+				}
+				var alerts []Alert
+				err := json.NewDecoder(r.Body).Decode(&alerts)
+				if err != nil {
+					t.Error(err)
+				}
+				received <- alerts
+			}))
+			defer server.Close()
+
+			a, err := New(&config.AlertmanagerConfig{
+				URL:            server.URL + "/",
+				AlertName:      "KubernetesEvent",
+				ResolveTimeout: config.Duration(5 * time.Minute),
+				Labels:         map[string]string{"severity": "warning"},
+				Annotations:    map[string]string{"runbook": "https://example.com/runbook"},
+			}, tc.cluster)
+			if err != nil {
+				t.Fatal(err)
+			}
+			a.now = func() time.Time { return now }
+
+			err = a.OnEvents(context.Background(), tc.events)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			alerts := <-received
+			if len(alerts) != len(tc.expected) {
+				t.Fatalf("expected %d alerts, got %d", len(tc.expected), len(alerts))
+			}
+			for i, expected := range tc.expected {
+				got := alerts[i]
+				if !reflect.DeepEqual(got.Labels, expected.Labels) {
+					t.Errorf("expected labels %v, got %v", expected.Labels, got.Labels)
+				}
+				if !reflect.DeepEqual(got.Annotations, expected.Annotations) {
+					t.Errorf("expected annotations %v, got %v", expected.Annotations, got.Annotations)
+				}
+				if !got.StartsAt.Equal(expected.StartsAt) {
+					t.Errorf("expected startsAt %v, got %v", expected.StartsAt, got.StartsAt)
+				}
+				if !got.EndsAt.Equal(expected.EndsAt) {
+					t.Errorf("expected endsAt %v, got %v", expected.EndsAt, got.EndsAt)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,190 @@
+/*
+Copyright 2020 Red Hat, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package eventlog
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rhobs/kube-events-exporter/internal/config"
+	"gopkg.in/natefinch/lumberjack.v2"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// Fields that can be written for each Event.
+const (
+	FieldTime                = "time"
+	FieldName                = "name"
+	FieldNamespace           = "namespace"
+	FieldInvolvedObject      = "involvedObject"
+	FieldReason              = "reason"
+	FieldType                = "type"
+	FieldMessage             = "message"
+	FieldCount               = "count"
+	FieldSource              = "source"
+	FieldReportingController = "reportingController"
+	FieldFirstTimestamp      = "firstTimestamp"
+	FieldLastTimestamp       = "lastTimestamp"
+	FieldEventTime           = "eventTime"
+	// FieldCluster is the cluster of the Event, which is only written
+	// when Events are collected from several clusters.
+	FieldCluster = "cluster"
+)
+
+var allFields = []string{
+	FieldTime,
+	FieldName,
+	FieldNamespace,
+	FieldInvolvedObject,
+	FieldReason,
+	FieldType,
+	FieldMessage,
+	FieldCount,
+	FieldSource,
+	FieldReportingController,
+	FieldFirstTimestamp,
+	FieldLastTimestamp,
+	FieldEventTime,
+	FieldCluster,
+}
+
+// Writer is a sink.Sink writing Events as JSON lines.
+type Writer struct {
+	lock    sync.Mutex
+	out     io.Writer
+	fields  []string
+	cluster string
+}
+
+// NewWriter returns a Writer configured from cfg, writing the Events of the
+// given cluster. Events are written to stdout unless a path is configured, in
+// which case the file is rotated by size.
+func NewWriter(cfg *config.EventLogConfig, cluster string) (*Writer, error) {
+	var out io.Writer = os.Stdout
+	if cfg.Path != "" {
+		out = &lumberjack.Logger{
+			Filename:   cfg.Path,
+			MaxSize:    cfg.MaxSizeMB,
+			MaxBackups: cfg.MaxBackups,
+		}
+	}
+	return newWriter(out, cfg.Fields, cluster)
+}
+
+func newWriter(out io.Writer, fields []string, cluster string) (*Writer, error) {
+	if len(fields) == 0 {
+		fields = allFields
+	}
+
+	for _, field := range fields {
+		if !validField(field) {
+			return nil, errors.Errorf("unknown event log field %q", field)
+		}
+	}
+
+	return &Writer{
+		out:     out,
+		fields:  fields,
+		cluster: cluster,
+	}, nil
+}
+
+// OnEvent implements the sink.Sink interface. Events that didn't occur again
+// since they were last seen are not written.
+func (w *Writer) OnEvent(_ context.Context, ev *v1.Event, nbNew float64) error {
+	if nbNew <= 0 {
+		return nil
+	}
+
+	record := make(map[string]interface{}, len(w.fields))
+	for _, field := range w.fields {
+		if field == FieldCluster {
+			if w.cluster != "" {
+				record[field] = w.cluster
+			}
+			continue
+		}
+		record[field] = fieldValue(ev, nbNew, field)
+	}
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return errors.Wrap(err, "marshal event")
+	}
+	line = append(line, '\n')
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	_, err = w.out.Write(line)
+	return errors.Wrap(err, "write event")
+}
+
+// Close closes the underlying file if any.
+func (w *Writer) Close() error {
+	if closer, ok := w.out.(io.Closer); ok && w.out != os.Stdout {
+		return closer.Close()
+	}
+	return nil
+}
+
+func validField(field string) bool {
+	for _, f := range allFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+func fieldValue(ev *v1.Event, nbNew float64, field string) interface{} {
+	switch field {
+	case FieldTime:
+		return time.Now().UTC().Format(time.RFC3339Nano)
+	case FieldName:
+		return ev.Name
+	case FieldNamespace:
+		return ev.Namespace
+	case FieldInvolvedObject:
+		return ev.InvolvedObject
+	case FieldReason:
+		return ev.Reason
+	case FieldType:
+		return ev.Type
+	case FieldMessage:
+		return ev.Message
+	case FieldCount:
+		return nbNew
+	case FieldSource:
+		return ev.Source
+	case FieldReportingController:
+		return ev.ReportingController
+	case FieldFirstTimestamp:
+		return ev.FirstTimestamp
+	case FieldLastTimestamp:
+		return ev.LastTimestamp
+	case FieldEventTime:
+		return ev.EventTime
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2020 Red Hat, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package eventlog
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestWriter(t *testing.T) {
+	ev := &v1.Event{
+		Type:           "Warning",
+		Reason:         "BackOff",
+		Message:        "Back-off restarting failed container",
+		InvolvedObject: v1.ObjectReference{Kind: "Pod", Namespace: "default", Name: "nginx"},
+	}
+
+	testCases := []struct {
+		desc     string
+		cluster  string
+		fields   []string
+		expected string
+	}{
+		{
+			desc:     "SelectedFields",
+			fields:   []string{FieldType, FieldReason, FieldCount},
+			expected: `{"count":2,"reason":"BackOff","type":"Warning"}` + "\n",
+		},
+		{
+			desc:     "InvolvedObject",
+			fields:   []string{FieldInvolvedObject},
+			expected: `{"involvedObject":{"kind":"Pod","namespace":"default","name":"nginx"}}` + "\n",
+		},
+		{
+			desc:     "NoCluster",
+			fields:   []string{FieldCluster, FieldType},
+			expected: `{"type":"Warning"}` + "\n",
+		},
+		{
+			desc:     "Cluster",
+			cluster:  "prod",
+			fields:   []string{FieldCluster, FieldType},
+			expected: `{"cluster":"prod","type":"Warning"}` + "\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+			out := &bytes.Buffer{}
+			w, err := newWriter(out, tc.fields, tc.cluster)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = w.OnEvent(context.Background(), ev, 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if out.String() != tc.expected {
+				t.Fatalf("expected %s, got %s", tc.expected, out.String())
+			}
+		})
+	}
+}
+
+func TestNewWriterUnknownField(t *testing.T) {
+	_, err := newWriter(&bytes.Buffer{}, []string{"unknown"}, "")
+	if err == nil {
+		t.Fatal("expected unknown field to be rejected")
+	}
+}
@@ -0,0 +1,187 @@
+/*
+Copyright 2020 Red Hat, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package otlp
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rhobs/kube-events-exporter/internal/config"
+	"github.com/rhobs/kube-events-exporter/internal/httpclient"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	metricsPath = "/v1/metrics"
+	logsPath    = "/v1/logs"
+)
+
+// client sends OTLP requests to a receiver.
+type client interface {
+	exportMetrics(ctx context.Context, req *colmetricspb.ExportMetricsServiceRequest) error
+	exportLogs(ctx context.Context, req *collogspb.ExportLogsServiceRequest) error
+	Close() error
+}
+
+type grpcClient struct {
+	cfg     *config.OTLPConfig
+	conn    *grpc.ClientConn
+	metrics colmetricspb.MetricsServiceClient
+	logs    collogspb.LogsServiceClient
+}
+
+func newGRPCClient(cfg *config.OTLPConfig) (*grpcClient, error) {
+	creds := insecure.NewCredentials()
+	if !cfg.Insecure {
+		tlsConfig, err := httpclient.NewTLSConfig(cfg.TLSConfig)
+		if err != nil {
+			return nil, errors.Wrap(err, "create TLS config")
+		}
+		creds = credentials.NewTLS(tlsConfig)
+	}
+
+	// The connection is established lazily so that the exporter starts
+	// even if the receiver is unavailable.
+	conn, err := grpc.Dial(cfg.Endpoint, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, errors.Wrapf(err, "dial %s", cfg.Endpoint)
+	}
+
+	return &grpcClient{
+		cfg:     cfg,
+		conn:    conn,
+		metrics: colmetricspb.NewMetricsServiceClient(conn),
+		logs:    collogspb.NewLogsServiceClient(conn),
+	}, nil
+}
+
+func (c *grpcClient) exportMetrics(ctx context.Context, req *colmetricspb.ExportMetricsServiceRequest) error {
+	ctx, cancel, err := c.context(ctx)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	_, err = c.metrics.Export(ctx, req)
+	return errors.Wrapf(err, "export metrics to %s", c.cfg.Endpoint)
+}
+
+func (c *grpcClient) exportLogs(ctx context.Context, req *collogspb.ExportLogsServiceRequest) error {
+	ctx, cancel, err := c.context(ctx)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	_, err = c.logs.Export(ctx, req)
+	return errors.Wrapf(err, "export logs to %s", c.cfg.Endpoint)
+}
+
+// context returns the context of a request, carrying the configured headers
+// as gRPC metadata.
+func (c *grpcClient) context(ctx context.Context) (context.Context, context.CancelFunc, error) {
+	headers, err := httpclient.Headers(c.cfg.HTTPClientConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	md := metadata.MD{}
+	for name, values := range headers {
+		md.Append(strings.ToLower(name), values...)
+	}
+	ctx = metadata.NewOutgoingContext(ctx, md)
+
+	if c.cfg.Timeout > 0 {
+		ctx, cancel := context.WithTimeout(ctx, time.Duration(c.cfg.Timeout))
+		return ctx, cancel, nil
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	return ctx, cancel, nil
+}
+
+func (c *grpcClient) Close() error {
+	return c.conn.Close()
+}
+
+type httpClient struct {
+	endpoint string
+	client   *http.Client
+}
+
+func newHTTPClient(cfg *config.OTLPConfig) (*httpClient, error) {
+	client, err := httpclient.New(cfg.HTTPClientConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return &httpClient{
+		endpoint: strings.TrimSuffix(cfg.Endpoint, "/"),
+		client:   client,
+	}, nil
+}
+
+func (c *httpClient) exportMetrics(ctx context.Context, req *colmetricspb.ExportMetricsServiceRequest) error {
+	return c.export(ctx, metricsPath, req)
+}
+
+func (c *httpClient) exportLogs(ctx context.Context, req *collogspb.ExportLogsServiceRequest) error {
+	return c.export(ctx, logsPath, req)
+}
+
+func (c *httpClient) export(ctx context.Context, path string, msg proto.Message) error {
+	body, err := proto.Marshal(msg)
+	if err != nil {
+		return errors.Wrap(err, "marshal request")
+	}
+
+	url := c.endpoint + path
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "create request")
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "export to %s", url)
+	}
+	defer resp.Body.Close()
+	// Drain the body to reuse the connection.
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode/100 != 2 {
+		return errors.Errorf("export to %s: unexpected status %s", url, resp.Status)
+	}
+	return nil
+}
+
+func (c *httpClient) Close() error {
+	c.client.CloseIdleConnections()
+	return nil
+}
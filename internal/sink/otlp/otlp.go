@@ -0,0 +1,330 @@
+/*
+Copyright 2020 Red Hat, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package otlp implements a sink exporting kube_events_total and the Events
+// with the OpenTelemetry protocol.
+package otlp
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rhobs/kube-events-exporter/internal/config"
+	"github.com/rhobs/kube-events-exporter/internal/sink"
+	"github.com/rhobs/kube-events-exporter/internal/version"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+
+	dto "github.com/prometheus/client_model/go"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog"
+)
+
+const scopeName = "github.com/rhobs/kube-events-exporter"
+
+// semanticKinds are the kinds of involved objects having dedicated attributes
+// in the Kubernetes semantic conventions.
+var semanticKinds = map[string]struct{}{
+	"Pod":         {},
+	"Node":        {},
+	"Deployment":  {},
+	"ReplicaSet":  {},
+	"StatefulSet": {},
+	"DaemonSet":   {},
+	"Job":         {},
+	"CronJob":     {},
+	"Namespace":   {},
+}
+
+// OTLP is a sink.BatchSink exporting the Events as OTLP log records. It also
+// periodically exports the metrics gathered from its gatherer as OTLP data
+// points.
+type OTLP struct {
+	client   client
+	gatherer prometheus.Gatherer
+	cfg      *config.OTLPConfig
+	cluster  string
+	start    time.Time
+}
+
+// New returns an OTLP sink configured from cfg. The metrics exported are
+// gathered from the given gatherer, and the log records are attributed to
+// the given cluster unless it is empty.
+func New(cfg *config.OTLPConfig, gatherer prometheus.Gatherer, cluster string) (*OTLP, error) {
+	var (
+		c   client
+		err error
+	)
+	switch cfg.Protocol {
+	case config.OTLPHTTP:
+		c, err = newHTTPClient(cfg)
+	default:
+		c, err = newGRPCClient(cfg)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "create OTLP client")
+	}
+
+	return &OTLP{
+		client:   c,
+		gatherer: gatherer,
+		cfg:      cfg,
+		cluster:  cluster,
+		start:    time.Now(),
+	}, nil
+}
+
+// OnEvent implements the sink.Sink interface.
+func (o *OTLP) OnEvent(ctx context.Context, ev *v1.Event, delta float64) error {
+	return o.OnEvents(ctx, []sink.Event{{Event: ev, Delta: delta}})
+}
+
+// OnEvents implements the sink.BatchSink interface. The Events are exported
+// as log records grouped by involved object.
+func (o *OTLP) OnEvents(ctx context.Context, events []sink.Event) error {
+	if !o.cfg.Logs {
+		return nil
+	}
+	return o.client.exportLogs(ctx, newLogsRequest(events, o.cluster, time.Now()))
+}
+
+// ReceivesEvents implements the sink.EventsReceiver interface. Events are
+// only exported when logs are enabled.
+func (o *OTLP) ReceivesEvents() bool {
+	return o.cfg.Logs
+}
+
+// Run implements the sink.Runner interface. It exports the metrics at the
+// configured interval until stopCh is closed, and once more when it is closed
+// so that the last increments aren't lost.
+func (o *OTLP) Run(stopCh <-chan struct{}) {
+	if !o.cfg.Metrics {
+		return
+	}
+
+	export := func() {
+		err := o.exportMetrics(context.Background())
+		if err != nil {
+			klog.Errorf("failed to export OTLP metrics: %v", err)
+		}
+	}
+
+	ticker := time.NewTicker(time.Duration(o.cfg.MetricsInterval))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopCh:
+			export()
+			return
+		case <-ticker.C:
+			export()
+		}
+	}
+}
+
+// Close implements the io.Closer interface.
+func (o *OTLP) Close() error {
+	return o.client.Close()
+}
+
+func (o *OTLP) exportMetrics(ctx context.Context) error {
+	families, err := o.gatherer.Gather()
+	if err != nil {
+		return errors.Wrap(err, "gather metrics")
+	}
+	return o.client.exportMetrics(ctx, newMetricsRequest(families, o.start, time.Now()))
+}
+
+func newMetricsRequest(families []*dto.MetricFamily, start, now time.Time) *colmetricspb.ExportMetricsServiceRequest {
+	metrics := make([]*metricspb.Metric, 0, len(families))
+	for _, family := range families {
+		var dataPoints []*metricspb.NumberDataPoint
+		for _, m := range family.GetMetric() {
+			var value float64
+			switch family.GetType() {
+			case dto.MetricType_COUNTER:
+				value = m.GetCounter().GetValue()
+			case dto.MetricType_GAUGE:
+				value = m.GetGauge().GetValue()
+			default:
+				continue
+			}
+
+			attributes := make([]*commonpb.KeyValue, 0, len(m.GetLabel()))
+			for _, label := range m.GetLabel() {
+				// Empty label values are equivalent to missing labels
+				// in Prometheus.
+				if label.GetValue() == "" {
+					continue
+				}
+				attributes = append(attributes, stringAttribute(label.GetName(), label.GetValue()))
+			}
+
+			dataPoints = append(dataPoints, &metricspb.NumberDataPoint{
+				Attributes:        attributes,
+				StartTimeUnixNano: uint64(start.UnixNano()),
+				TimeUnixNano:      uint64(now.UnixNano()),
+				Value:             &metricspb.NumberDataPoint_AsDouble{AsDouble: value},
+			})
+		}
+
+		metric := &metricspb.Metric{
+			Name:        family.GetName(),
+			Description: family.GetHelp(),
+		}
+		switch family.GetType() {
+		case dto.MetricType_COUNTER:
+			metric.Data = &metricspb.Metric_Sum{Sum: &metricspb.Sum{
+				DataPoints:             dataPoints,
+				AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+				IsMonotonic:            true,
+			}}
+		case dto.MetricType_GAUGE:
+			metric.Data = &metricspb.Metric_Gauge{Gauge: &metricspb.Gauge{DataPoints: dataPoints}}
+		default:
+			klog.V(4).Infof("skipping OTLP export of metric %s of unsupported type %s", family.GetName(), family.GetType())
+			continue
+		}
+		metrics = append(metrics, metric)
+	}
+
+	return &colmetricspb.ExportMetricsServiceRequest{
+		ResourceMetrics: []*metricspb.ResourceMetrics{{
+			Resource: exporterResource(),
+			ScopeMetrics: []*metricspb.ScopeMetrics{{
+				Scope:   scope(),
+				Metrics: metrics,
+			}},
+		}},
+	}
+}
+
+func newLogsRequest(events []sink.Event, cluster string, now time.Time) *collogspb.ExportLogsServiceRequest {
+	req := &collogspb.ExportLogsServiceRequest{}
+	byObject := make(map[v1.ObjectReference]*logspb.ScopeLogs, len(events))
+	for _, item := range events {
+		ev := item.Event
+		object := ev.InvolvedObject
+		scopeLogs, ok := byObject[object]
+		if !ok {
+			scopeLogs = &logspb.ScopeLogs{Scope: scope()}
+			byObject[object] = scopeLogs
+			req.ResourceLogs = append(req.ResourceLogs, &logspb.ResourceLogs{
+				Resource:  objectResource(cluster, object),
+				ScopeLogs: []*logspb.ScopeLogs{scopeLogs},
+			})
+		}
+		scopeLogs.LogRecords = append(scopeLogs.LogRecords, newLogRecord(item, now))
+	}
+	return req
+}
+
+func newLogRecord(item sink.Event, now time.Time) *logspb.LogRecord {
+	ev := item.Event
+	record := &logspb.LogRecord{
+		ObservedTimeUnixNano: uint64(now.UnixNano()),
+		SeverityText:         ev.Type,
+		Body:                 &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: ev.Message}},
+		Attributes: []*commonpb.KeyValue{
+			stringAttribute("k8s.event.name", ev.Name),
+			stringAttribute("k8s.event.uid", string(ev.UID)),
+			stringAttribute("k8s.event.reason", ev.Reason),
+			stringAttribute("k8s.event.action", ev.Action),
+			stringAttribute("k8s.event.reporting_controller", ev.ReportingController),
+			intAttribute("k8s.event.count", int64(ev.Count)),
+			{Key: "k8s.event.delta", Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: item.Delta}}},
+		},
+	}
+
+	if lastSeen := sink.LastSeen(ev); !lastSeen.IsZero() {
+		record.TimeUnixNano = uint64(lastSeen.UnixNano())
+	}
+
+	switch ev.Type {
+	case v1.EventTypeWarning:
+		record.SeverityNumber = logspb.SeverityNumber_SEVERITY_NUMBER_WARN
+	case v1.EventTypeNormal:
+		record.SeverityNumber = logspb.SeverityNumber_SEVERITY_NUMBER_INFO
+	}
+
+	return record
+}
+
+// objectResource returns the resource of the Event involved object in the given
+// cluster, described with the Kubernetes semantic conventions.
+func objectResource(cluster string, object v1.ObjectReference) *resourcepb.Resource {
+	attributes := []*commonpb.KeyValue{
+		stringAttribute("k8s.object.kind", object.Kind),
+		stringAttribute("k8s.object.name", object.Name),
+		stringAttribute("k8s.object.uid", string(object.UID)),
+		stringAttribute("k8s.object.api_version", object.APIVersion),
+	}
+	if object.FieldPath != "" {
+		attributes = append(attributes, stringAttribute("k8s.object.fieldpath", object.FieldPath))
+	}
+	if cluster != "" {
+		attributes = append(attributes, stringAttribute("k8s.cluster.name", cluster))
+	}
+	if object.Namespace != "" {
+		attributes = append(attributes, stringAttribute("k8s.namespace.name", object.Namespace))
+	}
+	if _, ok := semanticKinds[object.Kind]; ok {
+		prefix := "k8s." + strings.ToLower(object.Kind)
+		attributes = append(attributes,
+			stringAttribute(prefix+".name", object.Name),
+			stringAttribute(prefix+".uid", string(object.UID)),
+		)
+	}
+	return &resourcepb.Resource{Attributes: attributes}
+}
+
+func exporterResource() *resourcepb.Resource {
+	attributes := []*commonpb.KeyValue{
+		stringAttribute("service.name", "kube-events-exporter"),
+	}
+	if v := version.GetVersion(); v != "" {
+		attributes = append(attributes, stringAttribute("service.version", v))
+	}
+	return &resourcepb.Resource{Attributes: attributes}
+}
+
+func scope() *commonpb.InstrumentationScope {
+	return &commonpb.InstrumentationScope{
+		Name:    scopeName,
+		Version: version.GetVersion(),
+	}
+}
+
+func stringAttribute(key, value string) *commonpb.KeyValue {
+	return &commonpb.KeyValue{
+		Key:   key,
+		Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: value}},
+	}
+}
+
+func intAttribute(key string, value int64) *commonpb.KeyValue {
+	return &commonpb.KeyValue{
+		Key:   key,
+		Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: value}},
+	}
+}
@@ -0,0 +1,301 @@
+/*
+Copyright 2020 Red Hat, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package otlp
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rhobs/kube-events-exporter/internal/config"
+	"github.com/rhobs/kube-events-exporter/internal/sink"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// receiver is an OTLP receiver stub recording the requests it receives.
+type receiver struct {
+	metrics chan *colmetricspb.ExportMetricsServiceRequest
+	logs    chan *collogspb.ExportLogsServiceRequest
+	headers chan string
+}
+
+func newReceiver() *receiver {
+	return &receiver{
+		metrics: make(chan *colmetricspb.ExportMetricsServiceRequest, 1),
+		logs:    make(chan *collogspb.ExportLogsServiceRequest, 1),
+		headers: make(chan string, 2),
+	}
+}
+
+type metricsService struct {
+	colmetricspb.UnimplementedMetricsServiceServer
+	*receiver
+}
+
+func (s metricsService) Export(ctx context.Context, req *colmetricspb.ExportMetricsServiceRequest) (*colmetricspb.ExportMetricsServiceResponse, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	s.headers <- firstValue(md.Get("x-scope-orgid"))
+	s.metrics <- req
+	return &colmetricspb.ExportMetricsServiceResponse{}, nil
+}
+
+type logsService struct {
+	collogspb.UnimplementedLogsServiceServer
+	*receiver
+}
+
+func (s logsService) Export(ctx context.Context, req *collogspb.ExportLogsServiceRequest) (*collogspb.ExportLogsServiceResponse, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	s.headers <- firstValue(md.Get("x-scope-orgid"))
+	s.logs <- req
+	return &collogspb.ExportLogsServiceResponse{}, nil
+}
+
+func (r *receiver) startGRPC(t *testing.T) string {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	colmetricspb.RegisterMetricsServiceServer(server, metricsService{receiver: r})
+	collogspb.RegisterLogsServiceServer(server, logsService{receiver: r})
+	go func() {
+		_ = server.Serve(lis)
+	}()
+	t.Cleanup(server.Stop)
+	return lis.Addr().String()
+}
+
+func (r *receiver) startHTTP(t *testing.T) string {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if got := req.Header.Get("Content-Type"); got != "application/x-protobuf" {
+			t.Errorf("expected protobuf content type, got %q", got)
+		}
+		body, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		r.headers <- req.Header.Get("X-Scope-OrgID")
+
+		switch req.URL.Path {
+		case metricsPath:
+			msg := &colmetricspb.ExportMetricsServiceRequest{}
+			if err := proto.Unmarshal(body, msg); err != nil {
+				t.Error(err)
+			}
+			r.metrics <- msg
+		case logsPath:
+			msg := &collogspb.ExportLogsServiceRequest{}
+			if err := proto.Unmarshal(body, msg); err != nil {
+				t.Error(err)
+			}
+			r.logs <- msg
+		default:
+			t.Errorf("unexpected path %s", req.URL.Path)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func TestOTLP(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		protocol config.OTLPProtocol
+	}{
+		{
+			desc:     "GRPC",
+			protocol: config.OTLPGRPC,
+		},
+		{
+			desc:     "HTTP",
+			protocol: config.OTLPHTTP,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+			r := newReceiver()
+			var endpoint string
+			if tc.protocol == config.OTLPGRPC {
+				endpoint = r.startGRPC(t)
+			} else {
+				endpoint = r.startHTTP(t)
+			}
+
+			registry := prometheus.NewRegistry()
+			eventsTotal := prometheus.NewCounterVec(prometheus.CounterOpts{
+				Name: "kube_events_total",
+				Help: "Count of all Kubernetes Events",
+			}, []string{"type", "reason"})
+			registry.MustRegister(eventsTotal)
+			eventsTotal.WithLabelValues("Warning", "BackOff").Add(3)
+
+			o, err := New(&config.OTLPConfig{
+				Endpoint: endpoint,
+				Protocol: tc.protocol,
+				Insecure: true,
+				Metrics:  true,
+				Logs:     true,
+				HTTPClientConfig: config.HTTPClientConfig{
+					Headers: map[string]string{"X-Scope-OrgID": "tenant"},
+				},
+			}, registry, "prod-eu")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer o.Close()
+
+			err = o.exportMetrics(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkHeader(t, r)
+			metrics := <-r.metrics
+			metric := metrics.GetResourceMetrics()[0].GetScopeMetrics()[0].GetMetrics()[0]
+			if metric.GetName() != "kube_events_total" {
+				t.Fatalf("expected kube_events_total, got %s", metric.GetName())
+			}
+			sum := metric.GetSum()
+			if !sum.GetIsMonotonic() || len(sum.GetDataPoints()) != 1 {
+				t.Fatalf("expected a monotonic sum with one data point, got %v", sum)
+			}
+			dataPoint := sum.GetDataPoints()[0]
+			if dataPoint.GetAsDouble() != 3 {
+				t.Fatalf("expected value 3, got %f", dataPoint.GetAsDouble())
+			}
+			checkAttributes(t, dataPoint.GetAttributes(), map[string]string{
+				"type":   "Warning",
+				"reason": "BackOff",
+			})
+
+			ev := &v1.Event{
+				ObjectMeta: metav1.ObjectMeta{Name: "nginx.1", Namespace: "default"},
+				InvolvedObject: v1.ObjectReference{
+					Kind:      "Pod",
+					Namespace: "default",
+					Name:      "nginx",
+					UID:       "4e1b7e0c",
+				},
+				Type:    v1.EventTypeWarning,
+				Reason:  "BackOff",
+				Message: "Back-off restarting failed container",
+				Count:   3,
+			}
+			err = o.OnEvents(context.Background(), []sink.Event{{Event: ev, Delta: 1}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkHeader(t, r)
+			logs := <-r.logs
+			resourceLogs := logs.GetResourceLogs()[0]
+			checkAttributes(t, resourceLogs.GetResource().GetAttributes(), map[string]string{
+				"k8s.cluster.name":   "prod-eu",
+				"k8s.namespace.name": "default",
+				"k8s.pod.name":       "nginx",
+				"k8s.pod.uid":        "4e1b7e0c",
+				"k8s.object.kind":    "Pod",
+			})
+			record := resourceLogs.GetScopeLogs()[0].GetLogRecords()[0]
+			if record.GetBody().GetStringValue() != ev.Message {
+				t.Fatalf("expected body %q, got %q", ev.Message, record.GetBody().GetStringValue())
+			}
+			if record.GetSeverityText() != v1.EventTypeWarning {
+				t.Fatalf("expected severity %s, got %s", v1.EventTypeWarning, record.GetSeverityText())
+			}
+			checkAttributes(t, record.GetAttributes(), map[string]string{
+				"k8s.event.name":   "nginx.1",
+				"k8s.event.reason": "BackOff",
+			})
+		})
+	}
+}
+
+func TestOTLPRunExportsOnStop(t *testing.T) {
+	r := newReceiver()
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "kube_events_total",
+		Help: "Count of all Kubernetes Events",
+	}))
+
+	o, err := New(&config.OTLPConfig{
+		Endpoint:        r.startHTTP(t),
+		Protocol:        config.OTLPHTTP,
+		Metrics:         true,
+		MetricsInterval: config.Duration(time.Hour),
+	}, registry, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer o.Close()
+	if o.ReceivesEvents() {
+		t.Fatal("expected no events to be received with logs disabled")
+	}
+
+	stopCh := make(chan struct{})
+	close(stopCh)
+	o.Run(stopCh)
+
+	select {
+	case <-r.metrics:
+	default:
+		t.Fatal("expected metrics to be exported when stopping")
+	}
+}
+
+func checkHeader(t *testing.T, r *receiver) {
+	t.Helper()
+	if got := <-r.headers; got != "tenant" {
+		t.Fatalf("expected X-Scope-OrgID header tenant, got %q", got)
+	}
+}
+
+func checkAttributes(t *testing.T, attributes []*commonpb.KeyValue, expected map[string]string) {
+	t.Helper()
+	got := make(map[string]string, len(attributes))
+	for _, kv := range attributes {
+		got[kv.GetKey()] = kv.GetValue().GetStringValue()
+	}
+	for key, value := range expected {
+		if got[key] != value {
+			t.Errorf("expected attribute %s=%q, got %q", key, value, got[key])
+		}
+	}
+}
+
+func firstValue(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
@@ -0,0 +1,348 @@
+/*
+Copyright 2020 Red Hat, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sink
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rhobs/kube-events-exporter/internal/config"
+	"github.com/rhobs/kube-events-exporter/internal/sink/eventlog"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog"
+)
+
+// QueueMetrics stores the pointers of the sink queue metrics.
+type QueueMetrics struct {
+	sentTotal    *prometheus.CounterVec
+	failedTotal  *prometheus.CounterVec
+	droppedTotal *prometheus.CounterVec
+	retriesTotal *prometheus.CounterVec
+	length       *prometheus.GaugeVec
+}
+
+// NewQueueMetrics takes in a prometheus registerer and initializes and
+// registers sink queue metrics.
+func NewQueueMetrics(registry prometheus.Registerer) *QueueMetrics {
+	metrics := &QueueMetrics{
+		sentTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "kube_events_exporter_sink_events_sent_total",
+				Help: "Number of Events successfully sent to a sink",
+			},
+			[]string{"sink"},
+		),
+		failedTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "kube_events_exporter_sink_events_failed_total",
+				Help: "Number of Events that failed to be sent to a sink after all retries",
+			},
+			[]string{"sink"},
+		),
+		droppedTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "kube_events_exporter_sink_events_dropped_total",
+				Help: "Number of Events dropped because the queue of a sink was full",
+			},
+			[]string{"sink"},
+		),
+		retriesTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "kube_events_exporter_sink_retries_total",
+				Help: "Number of times sending an Event to a sink was retried",
+			},
+			[]string{"sink"},
+		),
+		length: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "kube_events_exporter_sink_queue_length",
+				Help: "Number of Events waiting in the queue of a sink",
+			},
+			[]string{"sink"},
+		),
+	}
+	registry.MustRegister(
+		metrics.sentTotal,
+		metrics.failedTotal,
+		metrics.droppedTotal,
+		metrics.retriesTotal,
+		metrics.length,
+	)
+	return metrics
+}
+
+// Queue is a Sink buffering Events in a bounded queue before sending them to
+// the wrapped Sink. Events are sent in batches if the wrapped Sink is a
+// BatchSink. Failed Events are retried with an exponential backoff and written
+// to the dead letter log once all retries failed.
+type Queue struct {
+	name       string
+	sink       Sink
+	cfg        config.QueueConfig
+	items      chan Event
+	deadLetter *eventlog.Writer
+	// ignoreEvents is set when the wrapped Sink has no use of the Events.
+	ignoreEvents bool
+
+	// ctx is the context of the requests to the wrapped Sink. It is only
+	// cancelled if flushing the queue times out.
+	ctx    context.Context
+	cancel context.CancelFunc
+	// pending is the incomplete batch left when Run returned.
+	pending []Event
+	done    chan struct{}
+
+	sentTotal    prometheus.Counter
+	failedTotal  prometheus.Counter
+	droppedTotal prometheus.Counter
+	retriesTotal prometheus.Counter
+	length       prometheus.Gauge
+}
+
+// NewQueue returns a new Queue wrapping the given Sink. The configuration is
+// expected to have been defaulted when it was loaded.
+func NewQueue(name string, sink Sink, cfg config.QueueConfig, metrics *QueueMetrics) (*Queue, error) {
+	q := &Queue{
+		name:         name,
+		sink:         sink,
+		cfg:          cfg,
+		items:        make(chan Event, cfg.Capacity),
+		sentTotal:    metrics.sentTotal.WithLabelValues(name),
+		failedTotal:  metrics.failedTotal.WithLabelValues(name),
+		droppedTotal: metrics.droppedTotal.WithLabelValues(name),
+		retriesTotal: metrics.retriesTotal.WithLabelValues(name),
+		length:       metrics.length.WithLabelValues(name),
+		done:         make(chan struct{}),
+	}
+	q.ctx, q.cancel = context.WithCancel(context.Background())
+
+	if _, ok := sink.(BatchSink); !ok {
+		q.cfg.BatchSize = 1
+	}
+	if receiver, ok := sink.(EventsReceiver); ok {
+		q.ignoreEvents = !receiver.ReceivesEvents()
+	}
+
+	if cfg.DeadLetterPath != "" {
+		var err error
+		q.deadLetter, err = eventlog.NewWriter(&config.EventLogConfig{Path: cfg.DeadLetterPath}, "")
+		if err != nil {
+			return nil, errors.Wrap(err, "create dead letter log")
+		}
+	}
+
+	return q, nil
+}
+
+// OnEvent implements the Sink interface. It queues the Event according to the
+// queue drop policy. Events that didn't occur again since they were last seen
+// are ignored, as well as all the Events if the wrapped Sink doesn't receive
+// them.
+func (q *Queue) OnEvent(ctx context.Context, ev *v1.Event, delta float64) error {
+	if delta <= 0 || q.ignoreEvents {
+		return nil
+	}
+
+	item := Event{Event: ev, Delta: delta}
+	defer q.length.Set(float64(len(q.items)))
+
+	switch q.cfg.DropPolicy {
+	case config.Block:
+		select {
+		case q.items <- item:
+			return nil
+		case <-ctx.Done():
+			q.droppedTotal.Inc()
+			return ctx.Err()
+		}
+	case config.DropOldest:
+		for {
+			select {
+			case q.items <- item:
+				return nil
+			default:
+			}
+
+			select {
+			case <-q.items:
+				q.droppedTotal.Inc()
+			default:
+			}
+		}
+	default:
+		select {
+		case q.items <- item:
+		default:
+			q.droppedTotal.Inc()
+		}
+		return nil
+	}
+}
+
+// Run sends the queued Events to the wrapped Sink until stopCh is closed. The
+// wrapped Sink is run alongside if it implements Runner. Events left in the
+// queue are sent by Flush.
+func (q *Queue) Run(stopCh <-chan struct{}) {
+	defer close(q.done)
+
+	if runner, ok := q.sink.(Runner); ok {
+		runnerDone := make(chan struct{})
+		go func() {
+			defer close(runnerDone)
+			runner.Run(stopCh)
+		}()
+		defer func() { <-runnerDone }()
+	}
+
+	batch := make([]Event, 0, q.cfg.BatchSize)
+	timer := time.NewTimer(0)
+	if !timer.Stop() {
+		<-timer.C
+	}
+
+	for {
+		select {
+		case <-stopCh:
+			q.pending = batch
+			return
+		case item := <-q.items:
+			q.length.Set(float64(len(q.items)))
+			batch = append(batch, item)
+			if len(batch) < q.cfg.BatchSize {
+				// Wait for the batch to be full or for the batch
+				// timeout to expire.
+				if len(batch) == 1 {
+					timer.Reset(time.Duration(q.cfg.BatchTimeout))
+				}
+				continue
+			}
+			if len(batch) > 1 && !timer.Stop() {
+				<-timer.C
+			}
+		case <-timer.C:
+		}
+
+		q.send(q.ctx, batch)
+		batch = make([]Event, 0, q.cfg.BatchSize)
+	}
+}
+
+// Flush waits for Run to return, sends the Events left in the queue and
+// closes the wrapped Sink if it implements io.Closer. Events that couldn't be
+// sent before ctx is done are written to the dead letter log.
+func (q *Queue) Flush(ctx context.Context) {
+	flushed := make(chan struct{})
+	defer close(flushed)
+	go func() {
+		select {
+		case <-ctx.Done():
+			// Abort the in-flight requests.
+			q.cancel()
+		case <-flushed:
+		}
+	}()
+
+	<-q.done
+
+	// Run returned, so Flush is the only consumer of the queue.
+	batch := q.pending
+	for len(q.items) > 0 {
+		batch = append(batch, <-q.items)
+		if len(batch) >= q.cfg.BatchSize {
+			q.send(q.ctx, batch)
+			batch = nil
+		}
+	}
+	if len(batch) > 0 {
+		q.send(q.ctx, batch)
+	}
+	q.length.Set(0)
+
+	q.close()
+}
+
+func (q *Queue) send(ctx context.Context, batch []Event) {
+	backoff := time.Duration(q.cfg.MinBackoff)
+	for retry := 0; ; retry++ {
+		err := q.sendOnce(ctx, batch)
+		if err == nil {
+			q.sentTotal.Add(float64(len(batch)))
+			return
+		}
+
+		if retry >= *q.cfg.MaxRetries || ctx.Err() != nil {
+			klog.Errorf("failed to send %d events to sink %s: %v", len(batch), q.name, err)
+			q.fail(ctx, batch)
+			return
+		}
+
+		q.retriesTotal.Inc()
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			q.fail(ctx, batch)
+			return
+		}
+
+		backoff *= 2
+		if backoff > time.Duration(q.cfg.MaxBackoff) {
+			backoff = time.Duration(q.cfg.MaxBackoff)
+		}
+	}
+}
+
+func (q *Queue) sendOnce(ctx context.Context, batch []Event) error {
+	if batchSink, ok := q.sink.(BatchSink); ok {
+		return batchSink.OnEvents(ctx, batch)
+	}
+	return q.sink.OnEvent(ctx, batch[0].Event, batch[0].Delta)
+}
+
+// fail writes the Events that couldn't be sent to the dead letter log.
+func (q *Queue) fail(ctx context.Context, batch []Event) {
+	q.failedTotal.Add(float64(len(batch)))
+	if q.deadLetter == nil {
+		return
+	}
+
+	for _, item := range batch {
+		err := q.deadLetter.OnEvent(ctx, item.Event, item.Delta)
+		if err != nil {
+			klog.Errorf("failed to write event to dead letter log of sink %s: %v", q.name, err)
+		}
+	}
+}
+
+func (q *Queue) close() {
+	if closer, ok := q.sink.(io.Closer); ok {
+		err := closer.Close()
+		if err != nil {
+			klog.Errorf("failed to close sink %s: %v", q.name, err)
+		}
+	}
+
+	if q.deadLetter != nil {
+		err := q.deadLetter.Close()
+		if err != nil {
+			klog.Errorf("failed to close dead letter log of sink %s: %v", q.name, err)
+		}
+	}
+}
@@ -0,0 +1,335 @@
+/*
+Copyright 2020 Red Hat, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sink
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rhobs/kube-events-exporter/internal/config"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+func newTestQueueConfig(capacity int, policy config.DropPolicy, maxRetries int) config.QueueConfig {
+	return config.QueueConfig{
+		Capacity:     capacity,
+		DropPolicy:   policy,
+		MaxRetries:   &maxRetries,
+		MinBackoff:   config.Duration(time.Millisecond),
+		MaxBackoff:   config.Duration(time.Millisecond),
+		BatchSize:    1,
+		BatchTimeout: config.Duration(time.Millisecond),
+	}
+}
+
+func TestQueueRetry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kube-events-exporter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	testCases := []struct {
+		desc       string
+		failures   int
+		maxRetries int
+		sent       float64
+		failed     float64
+	}{
+		{
+			desc:       "Success",
+			failures:   0,
+			maxRetries: 3,
+			sent:       1,
+		},
+		{
+			desc:       "SuccessAfterRetries",
+			failures:   2,
+			maxRetries: 3,
+			sent:       1,
+		},
+		{
+			desc:       "Failed",
+			failures:   4,
+			maxRetries: 3,
+			failed:     1,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			attempts := 0
+			s := Func(func(context.Context, *v1.Event, float64) error {
+				attempts++
+				if attempts <= tc.failures {
+					return errors.New("failed")
+				}
+				return nil
+			})
+
+			cfg := newTestQueueConfig(1, config.DropNewest, tc.maxRetries)
+			cfg.DeadLetterPath = filepath.Join(dir, tc.desc+".log")
+			q, err := NewQueue(tc.desc, s, cfg, NewQueueMetrics(prometheus.NewRegistry()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			q.send(context.Background(), []Event{{Event: &v1.Event{}, Delta: 1}})
+			q.close()
+
+			if got := testutil.ToFloat64(q.sentTotal); got != tc.sent {
+				t.Fatalf("expected %f sent events, got %f", tc.sent, got)
+			}
+			if got := testutil.ToFloat64(q.failedTotal); got != tc.failed {
+				t.Fatalf("expected %f failed events, got %f", tc.failed, got)
+			}
+
+			deadLetters, err := ioutil.ReadFile(cfg.DeadLetterPath)
+			if err != nil && !os.IsNotExist(err) {
+				t.Fatal(err)
+			}
+			if got := float64(bytes.Count(deadLetters, []byte("\n"))); got != tc.failed {
+				t.Fatalf("expected %f dead letters, got %f", tc.failed, got)
+			}
+		})
+	}
+}
+
+func TestQueueDropPolicy(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		policy   config.DropPolicy
+		expected []string
+	}{
+		{
+			desc:     "DropNewest",
+			policy:   config.DropNewest,
+			expected: []string{"first", "second"},
+		},
+		{
+			desc:     "DropOldest",
+			policy:   config.DropOldest,
+			expected: []string{"second", "third"},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+			received := make(chan string, 3)
+			s := Func(func(_ context.Context, ev *v1.Event, _ float64) error {
+				received <- ev.Name
+				return nil
+			})
+
+			q, err := NewQueue(tc.desc, s, newTestQueueConfig(2, tc.policy, 0), NewQueueMetrics(prometheus.NewRegistry()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, name := range []string{"first", "second", "third"} {
+				ev := &v1.Event{}
+				ev.Name = name
+				err := q.OnEvent(context.Background(), ev, 1)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if got := testutil.ToFloat64(q.droppedTotal); got != 1 {
+				t.Fatalf("expected 1 dropped event, got %f", got)
+			}
+
+			stopCh := make(chan struct{})
+			defer close(stopCh)
+			go q.Run(stopCh)
+
+			for _, expected := range tc.expected {
+				select {
+				case name := <-received:
+					if name != expected {
+						t.Fatalf("expected event %s, got %s", expected, name)
+					}
+				case <-time.After(wait.ForeverTestTimeout):
+					t.Fatalf("timed out waiting for event %s", expected)
+				}
+			}
+		})
+	}
+}
+
+type batchFunc func(ctx context.Context, events []Event) error
+
+func (f batchFunc) OnEvent(ctx context.Context, ev *v1.Event, delta float64) error {
+	return f(ctx, []Event{{Event: ev, Delta: delta}})
+}
+
+func (f batchFunc) OnEvents(ctx context.Context, events []Event) error {
+	return f(ctx, events)
+}
+
+func TestQueueBatch(t *testing.T) {
+	batches := make(chan int, 3)
+	s := batchFunc(func(_ context.Context, events []Event) error {
+		batches <- len(events)
+		return nil
+	})
+
+	cfg := newTestQueueConfig(10, config.DropNewest, 0)
+	cfg.BatchSize = 2
+	q, err := NewQueue("batch", s, cfg, NewQueueMetrics(prometheus.NewRegistry()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, delta := range []float64{1, 0, 1, 1} {
+		err := q.OnEvent(context.Background(), &v1.Event{}, delta)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go q.Run(stopCh)
+
+	// The third Event is sent on its own once the batch timeout expires.
+	for _, expected := range []int{2, 1} {
+		select {
+		case size := <-batches:
+			if size != expected {
+				t.Fatalf("expected batch of %d events, got %d", expected, size)
+			}
+		case <-time.After(wait.ForeverTestTimeout):
+			t.Fatalf("timed out waiting for batch of %d events", expected)
+		}
+	}
+}
+
+// metricsOnlySink is a Sink having no use of the Events.
+type metricsOnlySink struct {
+	Func
+}
+
+func (metricsOnlySink) ReceivesEvents() bool {
+	return false
+}
+
+func TestQueueIgnoreEvents(t *testing.T) {
+	s := metricsOnlySink{Func: func(_ context.Context, _ *v1.Event, _ float64) error {
+		t.Error("unexpected event sent to the sink")
+		return nil
+	}}
+	q, err := NewQueue("metrics", s, newTestQueueConfig(1, config.DropNewest, 0), NewQueueMetrics(prometheus.NewRegistry()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		err := q.OnEvent(context.Background(), &v1.Event{}, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stopCh := make(chan struct{})
+	go q.Run(stopCh)
+	close(stopCh)
+	q.Flush(context.Background())
+
+	for _, counter := range []prometheus.Counter{q.sentTotal, q.droppedTotal} {
+		if got := testutil.ToFloat64(counter); got != 0 {
+			t.Fatalf("expected no event counted, got %f", got)
+		}
+	}
+}
+
+func TestQueueFlush(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		timeout  time.Duration
+		failures bool
+		sent     float64
+		failed   float64
+	}{
+		{
+			desc:    "Flushed",
+			timeout: wait.ForeverTestTimeout,
+			sent:    3,
+		},
+		{
+			desc:     "TimedOut",
+			timeout:  10 * time.Millisecond,
+			failures: true,
+			failed:   3,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+			s := batchFunc(func(ctx context.Context, events []Event) error {
+				if tc.failures {
+					return errors.New("failed")
+				}
+				return nil
+			})
+
+			// Retry for longer than the flush timeout.
+			cfg := newTestQueueConfig(10, config.DropNewest, 1000)
+			cfg.BatchSize = 2
+			cfg.BatchTimeout = config.Duration(time.Hour)
+			q, err := NewQueue(tc.desc, s, cfg, NewQueueMetrics(prometheus.NewRegistry()))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			stopCh := make(chan struct{})
+			close(stopCh)
+			q.Run(stopCh)
+
+			for i := 0; i < 3; i++ {
+				err := q.OnEvent(context.Background(), &v1.Event{}, 1)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), tc.timeout)
+			defer cancel()
+			q.Flush(ctx)
+
+			if got := testutil.ToFloat64(q.sentTotal); got != tc.sent {
+				t.Fatalf("expected %f sent events, got %f", tc.sent, got)
+			}
+			if got := testutil.ToFloat64(q.failedTotal); got != tc.failed {
+				t.Fatalf("expected %f failed events, got %f", tc.failed, got)
+			}
+		})
+	}
+}
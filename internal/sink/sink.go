@@ -0,0 +1,79 @@
+/*
+Copyright 2020 Red Hat, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sink
+
+import (
+	"context"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// Sink receives the Events accepted by the exporter.
+type Sink interface {
+	// OnEvent is called with an Event that occurred delta times since it
+	// was last seen.
+	OnEvent(ctx context.Context, ev *v1.Event, delta float64) error
+}
+
+// Event is an Event that occurred Delta times since it was last seen.
+type Event struct {
+	Event *v1.Event
+	Delta float64
+}
+
+// BatchSink is a Sink able to receive several Events at once.
+type BatchSink interface {
+	Sink
+	// OnEvents is called with a batch of Events.
+	OnEvents(ctx context.Context, events []Event) error
+}
+
+// Runner is implemented by sinks having background work to do, such as
+// periodically pushing metrics. The sink queue runs it until stopCh is closed.
+type Runner interface {
+	Run(stopCh <-chan struct{})
+}
+
+// EventsReceiver is implemented by sinks that may have no use of the Events,
+// such as sinks configured to only export metrics. The sink queue doesn't
+// queue Events for them when ReceivesEvents returns false.
+type EventsReceiver interface {
+	ReceivesEvents() bool
+}
+
+// LastSeen returns the last time the Event occurred, or the zero time if it
+// is unknown. Depending on the API used by the reporting controller, it is
+// found in different fields.
+func LastSeen(ev *v1.Event) time.Time {
+	switch {
+	case !ev.LastTimestamp.IsZero():
+		return ev.LastTimestamp.Time
+	case ev.Series != nil && !ev.Series.LastObservedTime.IsZero():
+		return ev.Series.LastObservedTime.Time
+	default:
+		return ev.EventTime.Time
+	}
+}
+
+// Func is an adapter to use ordinary functions as Sink.
+type Func func(ctx context.Context, ev *v1.Event, delta float64) error
+
+// OnEvent implements the Sink interface.
+func (f Func) OnEvent(ctx context.Context, ev *v1.Event, delta float64) error {
+	return f(ctx, ev, delta)
+}
@@ -0,0 +1,141 @@
+/*
+Copyright 2020 Red Hat, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package webhook implements a sink posting Events to an HTTP endpoint.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"text/template"
+
+	"github.com/pkg/errors"
+	"github.com/rhobs/kube-events-exporter/internal/config"
+	"github.com/rhobs/kube-events-exporter/internal/httpclient"
+	"github.com/rhobs/kube-events-exporter/internal/sink"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// Webhook is a sink.BatchSink posting Events to an HTTP endpoint.
+type Webhook struct {
+	url      string
+	client   *http.Client
+	cluster  string
+	template *template.Template
+}
+
+// Payload is the data the request body is rendered from.
+type Payload struct {
+	Events []PayloadEvent `json:"events"`
+}
+
+// PayloadEvent is an Event that occurred Delta times since it was last seen.
+// Cluster is only set when Events are collected from several clusters.
+type PayloadEvent struct {
+	Cluster string    `json:"cluster,omitempty"`
+	Event   *v1.Event `json:"event"`
+	Delta   float64   `json:"delta"`
+}
+
+// New returns a Webhook configured from cfg, posting the Events of the given
+// cluster.
+func New(cfg *config.WebhookConfig, cluster string) (*Webhook, error) {
+	client, err := httpclient.New(cfg.HTTPClientConfig)
+	if err != nil {
+		return nil, errors.Wrap(err, "create HTTP client")
+	}
+
+	w := &Webhook{
+		url:     cfg.URL,
+		client:  client,
+		cluster: cluster,
+	}
+
+	if cfg.Template != "" {
+		w.template, err = template.New("webhook").Funcs(template.FuncMap{
+			"json": toJSON,
+		}).Parse(cfg.Template)
+		if err != nil {
+			return nil, errors.Wrap(err, "parse template")
+		}
+	}
+
+	return w, nil
+}
+
+// OnEvent implements the sink.Sink interface.
+func (w *Webhook) OnEvent(ctx context.Context, ev *v1.Event, delta float64) error {
+	return w.OnEvents(ctx, []sink.Event{{Event: ev, Delta: delta}})
+}
+
+// OnEvents implements the sink.BatchSink interface. The Events are posted in a
+// single request.
+func (w *Webhook) OnEvents(ctx context.Context, events []sink.Event) error {
+	payload := Payload{Events: make([]PayloadEvent, 0, len(events))}
+	for _, ev := range events {
+		payload.Events = append(payload.Events, PayloadEvent{Cluster: w.cluster, Event: ev.Event, Delta: ev.Delta})
+	}
+
+	body, err := w.render(payload)
+	if err != nil {
+		return errors.Wrap(err, "render payload")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "create request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "post events to %s", w.url)
+	}
+	defer resp.Body.Close()
+	// Drain the body to reuse the connection.
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode/100 != 2 {
+		return errors.Errorf("post events to %s: unexpected status %s", w.url, resp.Status)
+	}
+	return nil
+}
+
+func (w *Webhook) render(payload Payload) ([]byte, error) {
+	if w.template == nil {
+		return json.Marshal(payload)
+	}
+
+	var buf bytes.Buffer
+	err := w.template.Execute(&buf, payload)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func toJSON(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
@@ -0,0 +1,131 @@
+/*
+Copyright 2020 Red Hat, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rhobs/kube-events-exporter/internal/config"
+	"github.com/rhobs/kube-events-exporter/internal/sink"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestWebhook(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		cluster  string
+		template string
+		status   int
+		expected string
+		valid    bool
+	}{
+		{
+			desc:     "Default",
+			status:   http.StatusOK,
+			expected: `{"events":[{"event":{"metadata":{"name":"first","creationTimestamp":null},"involvedObject":{},"reason":"BackOff","source":{},"firstTimestamp":null,"lastTimestamp":null,"eventTime":null,"reportingComponent":"","reportingInstance":""},"delta":1},{"event":{"metadata":{"name":"second","creationTimestamp":null},"involvedObject":{},"reason":"Failed","source":{},"firstTimestamp":null,"lastTimestamp":null,"eventTime":null,"reportingComponent":"","reportingInstance":""},"delta":2}]}`,
+			valid:    true,
+		},
+		{
+			desc:     "Template",
+			template: `{"text":"{{ range .Events }}{{ .Event.Name }}: {{ .Event.Reason }} ({{ .Delta }}) {{ end }}","reasons":[{{ range $i, $e := .Events }}{{ if $i }},{{ end }}{{ json $e.Event.Reason }}{{ end }}]}`,
+			status:   http.StatusAccepted,
+			expected: `{"text":"first: BackOff (1) second: Failed (2) ","reasons":["BackOff","Failed"]}`,
+			valid:    true,
+		},
+		{
+			desc:     "Cluster",
+			cluster:  "prod-eu",
+			template: `[{{ range $i, $e := .Events }}{{ if $i }},{{ end }}{{ json $e.Cluster }}{{ end }}]`,
+			status:   http.StatusOK,
+			expected: `["prod-eu","prod-eu"]`,
+			valid:    true,
+		},
+		{
+			desc:   "ServerError",
+			status: http.StatusInternalServerError,
+			valid:  false,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+			bodies := make(chan string, 1)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if got := r.Header.Get("Content-Type"); got != "application/json" {
+					t.Errorf("expected JSON content type, got %q", got)
+				}
+				if got := r.Header.Get("X-Source"); got != "kube-events-exporter" {
+					t.Errorf("expected custom header, got %q", got)
+				}
+				if got := r.Header.Get("Authorization"); got != "Bearer secret" {
+					t.Errorf("expected bearer token, got %q", got)
+				}
+				body, _ := ioutil.ReadAll(r.Body)
+				bodies <- string(body)
+				w.WriteHeader(tc.status)
+			}))
+			defer server.Close()
+
+			cfg := &config.WebhookConfig{
+				URL:      server.URL,
+				Template: tc.template,
+				HTTPClientConfig: config.HTTPClientConfig{
+					Headers:     map[string]string{"X-Source": "kube-events-exporter"},
+					BearerToken: "secret",
+				},
+			}
+			w, err := New(cfg, tc.cluster)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			events := []sink.Event{
+				{Event: newEvent("first", "BackOff"), Delta: 1},
+				{Event: newEvent("second", "Failed"), Delta: 2},
+			}
+			err = w.OnEvents(context.Background(), events)
+			if !tc.valid {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected events to be sent, got %v", err)
+			}
+
+			if body := <-bodies; body != tc.expected {
+				t.Fatalf("expected body %s, got %s", tc.expected, body)
+			}
+		})
+	}
+}
+
+func newEvent(name, reason string) *v1.Event {
+	return &v1.Event{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Reason:     reason,
+	}
+}
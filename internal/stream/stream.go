@@ -0,0 +1,194 @@
+/*
+Copyright 2020 Red Hat, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package stream
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rhobs/kube-events-exporter/pkg/informer"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+var (
+	// ErrTooManySubscribers is returned when subscribing to a Broadcaster
+	// which already has the maximum number of subscribers.
+	ErrTooManySubscribers = errors.New("too many subscribers")
+	// ErrClosed is returned when subscribing to a closed Broadcaster.
+	ErrClosed = errors.New("broadcaster closed")
+)
+
+// Message is an Event that occurred Delta times since it was last seen.
+// Cluster is only set when Events are collected from several clusters.
+type Message struct {
+	Cluster string    `json:"cluster,omitempty"`
+	Event   *v1.Event `json:"event"`
+	Delta   float64   `json:"delta"`
+}
+
+// Broadcaster is a Sink pushing the Events it receives to its subscribers.
+// Each subscriber has a bounded buffer, messages are dropped for subscribers
+// too slow to keep up.
+type Broadcaster struct {
+	maxSubscribers int
+	bufferSize     int
+
+	lock        sync.Mutex
+	closed      bool
+	subscribers map[*Subscription]struct{}
+
+	subscribersGauge prometheus.Gauge
+	droppedTotal     prometheus.Counter
+}
+
+// NewBroadcaster returns a new Broadcaster accepting at most maxSubscribers
+// subscribers, each buffering up to bufferSize messages. Its metrics are
+// registered in the given registry.
+func NewBroadcaster(maxSubscribers, bufferSize int, registry prometheus.Registerer) *Broadcaster {
+	b := &Broadcaster{
+		maxSubscribers: maxSubscribers,
+		bufferSize:     bufferSize,
+		subscribers:    map[*Subscription]struct{}{},
+		subscribersGauge: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "kube_events_exporter_stream_subscribers",
+				Help: "Number of subscribers to the Events stream",
+			},
+		),
+		droppedTotal: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Name: "kube_events_exporter_stream_messages_dropped_total",
+				Help: "Number of Events dropped because the buffer of a stream subscriber was full",
+			},
+		),
+	}
+	registry.MustRegister(b.subscribersGauge, b.droppedTotal)
+	return b
+}
+
+// OnEvent implements the Sink interface. Events that didn't occur again since
+// they were last seen are ignored.
+func (b *Broadcaster) OnEvent(_ context.Context, ev *v1.Event, delta float64) error {
+	b.publish(Message{Event: ev, Delta: delta})
+	return nil
+}
+
+// ClusterSink returns a Sink pushing the Events of the given cluster to the
+// subscribers, so that the Events of several clusters share a single stream.
+func (b *Broadcaster) ClusterSink(cluster string) *ClusterSink {
+	return &ClusterSink{broadcaster: b, cluster: cluster}
+}
+
+func (b *Broadcaster) publish(msg Message) {
+	if msg.Delta <= 0 {
+		return
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	for sub := range b.subscribers {
+		if !sub.selector.Matches(msg.Event) {
+			continue
+		}
+		select {
+		case sub.ch <- msg:
+		default:
+			b.droppedTotal.Inc()
+		}
+	}
+}
+
+// Subscribe returns a new Subscription to the Events matching the selector.
+func (b *Broadcaster) Subscribe(selector informer.Selector) (*Subscription, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.closed {
+		return nil, ErrClosed
+	}
+	if len(b.subscribers) >= b.maxSubscribers {
+		return nil, ErrTooManySubscribers
+	}
+
+	ch := make(chan Message, b.bufferSize)
+	sub := &Subscription{
+		C:           ch,
+		ch:          ch,
+		selector:    selector,
+		broadcaster: b,
+	}
+	b.subscribers[sub] = struct{}{}
+	b.subscribersGauge.Set(float64(len(b.subscribers)))
+	return sub, nil
+}
+
+// Close ends all the subscriptions, closing their channels, and rejects the
+// new ones.
+func (b *Broadcaster) Close() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.closed = true
+	for sub := range b.subscribers {
+		b.remove(sub)
+	}
+}
+
+func (b *Broadcaster) remove(sub *Subscription) {
+	if _, ok := b.subscribers[sub]; !ok {
+		return
+	}
+	delete(b.subscribers, sub)
+	close(sub.ch)
+	b.subscribersGauge.Set(float64(len(b.subscribers)))
+}
+
+// ClusterSink is a Sink pushing the Events of a cluster to the subscribers of
+// a Broadcaster.
+type ClusterSink struct {
+	broadcaster *Broadcaster
+	cluster     string
+}
+
+// OnEvent implements the Sink interface. Events that didn't occur again since
+// they were last seen are ignored.
+func (s *ClusterSink) OnEvent(_ context.Context, ev *v1.Event, delta float64) error {
+	s.broadcaster.publish(Message{Cluster: s.cluster, Event: ev, Delta: delta})
+	return nil
+}
+
+// Subscription receives the Events matching its selector on C, until it is
+// cancelled or the Broadcaster is closed.
+type Subscription struct {
+	C <-chan Message
+
+	ch          chan Message
+	selector    informer.Selector
+	broadcaster *Broadcaster
+}
+
+// Unsubscribe cancels the subscription and closes C.
+func (s *Subscription) Unsubscribe() {
+	s.broadcaster.lock.Lock()
+	defer s.broadcaster.lock.Unlock()
+
+	s.broadcaster.remove(s)
+}
@@ -0,0 +1,122 @@
+/*
+Copyright 2020 Red Hat, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package stream
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rhobs/kube-events-exporter/pkg/informer"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+func newTestEvent(name, reason string) *v1.Event {
+	ev := &v1.Event{Reason: reason}
+	ev.Name = name
+	return ev
+}
+
+func TestBroadcaster(t *testing.T) {
+	b := NewBroadcaster(2, 1, prometheus.NewRegistry())
+
+	all, err := b.Subscribe(informer.Selector{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	backOff, err := b.Subscribe(informer.Selector{Reason: "BackOff"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = b.Subscribe(informer.Selector{})
+	if err != ErrTooManySubscribers {
+		t.Fatalf("expected %v, got %v", ErrTooManySubscribers, err)
+	}
+	if got := testutil.ToFloat64(b.subscribersGauge); got != 2 {
+		t.Fatalf("expected 2 subscribers, got %f", got)
+	}
+
+	for _, ev := range []*v1.Event{
+		newTestEvent("pulled", "Pulled"),
+		newTestEvent("unchanged", "BackOff"),
+		newTestEvent("backoff", "BackOff"),
+	} {
+		delta := 1.0
+		if ev.Name == "unchanged" {
+			delta = 0
+		}
+		err := b.OnEvent(context.Background(), ev, delta)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The buffer of the first subscriber was full when the second Event
+	// was pushed.
+	if msg := <-all.C; msg.Event.Name != "pulled" {
+		t.Fatalf("expected event pulled, got %s", msg.Event.Name)
+	}
+	if msg := <-backOff.C; msg.Event.Name != "backoff" {
+		t.Fatalf("expected event backoff, got %s", msg.Event.Name)
+	}
+	if got := testutil.ToFloat64(b.droppedTotal); got != 1 {
+		t.Fatalf("expected 1 dropped message, got %f", got)
+	}
+
+	all.Unsubscribe()
+	if _, ok := <-all.C; ok {
+		t.Fatal("expected channel to be closed after unsubscribing")
+	}
+
+	b.Close()
+	if _, ok := <-backOff.C; ok {
+		t.Fatal("expected channel to be closed after closing the broadcaster")
+	}
+	// Unsubscribing after the broadcaster was closed is a no-op.
+	backOff.Unsubscribe()
+	if got := testutil.ToFloat64(b.subscribersGauge); got != 0 {
+		t.Fatalf("expected 0 subscribers, got %f", got)
+	}
+
+	_, err = b.Subscribe(informer.Selector{})
+	if err != ErrClosed {
+		t.Fatalf("expected %v, got %v", ErrClosed, err)
+	}
+}
+
+func TestBroadcasterClusterSinks(t *testing.T) {
+	b := NewBroadcaster(1, 2, prometheus.NewRegistry())
+	sub, err := b.Subscribe(informer.Selector{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, cluster := range []string{"prod-eu", "prod-us"} {
+		err := b.ClusterSink(cluster).OnEvent(context.Background(), newTestEvent(cluster, "BackOff"), 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, expected := range []string{"prod-eu", "prod-us"} {
+		if msg := <-sub.C; msg.Cluster != expected || msg.Event.Name != expected {
+			t.Fatalf("expected event of cluster %s, got %s of cluster %s", expected, msg.Event.Name, msg.Cluster)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+/*
+Copyright 2020 Red Hat, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package web
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+)
+
+// Config is the web configuration file, in the format of the Prometheus
+// exporter-toolkit.
+type Config struct {
+	TLSServerConfig  *TLSServerConfig  `json:"tls_server_config,omitempty"`
+	HTTPServerConfig HTTPServerConfig  `json:"http_server_config,omitempty"`
+	Users            map[string]string `json:"basic_auth_users,omitempty"`
+}
+
+// TLSServerConfig configures the TLS of the servers.
+type TLSServerConfig struct {
+	CertFile                 string   `json:"cert_file"`
+	KeyFile                  string   `json:"key_file"`
+	ClientAuth               string   `json:"client_auth_type,omitempty"`
+	ClientCAFile             string   `json:"client_ca_file,omitempty"`
+	MinVersion               string   `json:"min_version,omitempty"`
+	MaxVersion               string   `json:"max_version,omitempty"`
+	CipherSuites             []string `json:"cipher_suites,omitempty"`
+	PreferServerCipherSuites *bool    `json:"prefer_server_cipher_suites,omitempty"`
+	CurvePreferences         []string `json:"curve_preferences,omitempty"`
+}
+
+// HTTPServerConfig configures the HTTP of the servers.
+type HTTPServerConfig struct {
+	HTTP2   *bool             `json:"http2,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// allowedHeaders are the response headers that can be set in the web
+// configuration file. They are the security headers accepted by the
+// exporter-toolkit.
+var allowedHeaders = map[string]bool{
+	"Strict-Transport-Security": true,
+	"X-Content-Type-Options":    true,
+	"X-Frame-Options":           true,
+	"X-Xss-Protection":          true,
+	"Content-Security-Policy":   true,
+}
+
+var clientAuthTypes = map[string]tls.ClientAuthType{
+	"":                           tls.NoClientCert,
+	"NoClientCert":               tls.NoClientCert,
+	"RequestClientCert":          tls.RequestClientCert,
+	"RequireAnyClientCert":       tls.RequireAnyClientCert,
+	"VerifyClientCertIfGiven":    tls.VerifyClientCertIfGiven,
+	"RequireAndVerifyClientCert": tls.RequireAndVerifyClientCert,
+}
+
+var tlsVersions = map[string]uint16{
+	"TLS10": tls.VersionTLS10,
+	"TLS11": tls.VersionTLS11,
+	"TLS12": tls.VersionTLS12,
+	"TLS13": tls.VersionTLS13,
+}
+
+var curves = map[string]tls.CurveID{
+	"CurveP256": tls.CurveP256,
+	"CurveP384": tls.CurveP384,
+	"CurveP521": tls.CurveP521,
+	"X25519":    tls.X25519,
+}
+
+// LoadConfig loads the web configuration file at the given path.
+func LoadConfig(path string) (*Config, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "read web config file %s", path)
+	}
+
+	content, err = yaml.YAMLToJSON(content)
+	if err != nil {
+		return nil, errors.Wrapf(err, "convert web config file %s to JSON", path)
+	}
+
+	cfg := &Config{}
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.DisallowUnknownFields()
+	err = decoder.Decode(cfg)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unmarshal web config file %s", path)
+	}
+
+	err = cfg.validate()
+	if err != nil {
+		return nil, errors.Wrapf(err, "validate web config file %s", path)
+	}
+
+	return cfg, nil
+}
+
+func (cfg *Config) validate() error {
+	for header := range cfg.HTTPServerConfig.Headers {
+		if !allowedHeaders[http.CanonicalHeaderKey(header)] {
+			return fmt.Errorf("header %q can't be set", header)
+		}
+	}
+
+	for user, hash := range cfg.Users {
+		if hash == "" {
+			return fmt.Errorf("empty password hash for user %q", user)
+		}
+	}
+
+	if cfg.TLSServerConfig != nil {
+		// Building the TLS configuration checks the values, without
+		// loading the files which can still be created later on.
+		_, err := cfg.TLSServerConfig.tlsConfig(false)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tlsConfig returns the TLS configuration of the servers. The certificate and
+// the client CA are only loaded if loadFiles is true.
+func (cfg *TLSServerConfig) tlsConfig(loadFiles bool) (*tls.Config, error) {
+	if cfg.CertFile == "" || cfg.KeyFile == "" {
+		return nil, errors.New("cert_file and key_file are required")
+	}
+
+	tlsCfg := &tls.Config{
+		MinVersion:               tls.VersionTLS12,
+		PreferServerCipherSuites: true,
+	}
+
+	var ok bool
+	tlsCfg.ClientAuth, ok = clientAuthTypes[cfg.ClientAuth]
+	if !ok {
+		return nil, fmt.Errorf("invalid client_auth_type %q", cfg.ClientAuth)
+	}
+	if cfg.ClientCAFile == "" && tlsCfg.ClientAuth >= tls.VerifyClientCertIfGiven {
+		return nil, fmt.Errorf("client_ca_file is required with client_auth_type %q", cfg.ClientAuth)
+	}
+
+	if cfg.MinVersion != "" {
+		tlsCfg.MinVersion, ok = tlsVersions[cfg.MinVersion]
+		if !ok {
+			return nil, fmt.Errorf("invalid min_version %q", cfg.MinVersion)
+		}
+	}
+	if cfg.MaxVersion != "" {
+		tlsCfg.MaxVersion, ok = tlsVersions[cfg.MaxVersion]
+		if !ok {
+			return nil, fmt.Errorf("invalid max_version %q", cfg.MaxVersion)
+		}
+	}
+	if cfg.PreferServerCipherSuites != nil {
+		tlsCfg.PreferServerCipherSuites = *cfg.PreferServerCipherSuites
+	}
+
+	for _, name := range cfg.CipherSuites {
+		id, ok := cipherSuite(name)
+		if !ok {
+			return nil, fmt.Errorf("invalid cipher suite %q", name)
+		}
+		tlsCfg.CipherSuites = append(tlsCfg.CipherSuites, id)
+	}
+	for _, name := range cfg.CurvePreferences {
+		id, ok := curves[name]
+		if !ok {
+			return nil, fmt.Errorf("invalid curve %q", name)
+		}
+		tlsCfg.CurvePreferences = append(tlsCfg.CurvePreferences, id)
+	}
+
+	if !loadFiles {
+		return tlsCfg, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "load server certificate")
+	}
+	tlsCfg.Certificates = []tls.Certificate{cert}
+
+	if cfg.ClientCAFile != "" {
+		ca, err := ioutil.ReadFile(cfg.ClientCAFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "read client CA")
+		}
+		tlsCfg.ClientCAs = x509.NewCertPool()
+		if !tlsCfg.ClientCAs.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificate found in client CA %s", cfg.ClientCAFile)
+		}
+	}
+
+	return tlsCfg, nil
+}
+
+func cipherSuite(name string) (uint16, bool) {
+	for _, suite := range tls.CipherSuites() {
+		if suite.Name == name {
+			return suite.ID, true
+		}
+	}
+	return 0, false
+}
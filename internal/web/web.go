@@ -0,0 +1,292 @@
+/*
+Copyright 2020 Red Hat, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package web
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"net/http"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/bcrypt"
+
+	"k8s.io/klog"
+)
+
+const (
+	// maxAuthCacheSize bounds the cache of the basic auth results.
+	maxAuthCacheSize = 100
+	// dummyHash is compared with the password of unknown users so that
+	// they can't be guessed from the response time.
+	dummyHash = "$2a$10$JeGA88dEmYesJ1pjLFU4g.kVNVSfhcoTm57E8SXWOvDUg8Hpk0tR6"
+	// reloadInterval is the interval at which the web configuration file
+	// and the TLS certificates are checked for changes.
+	reloadInterval = 10 * time.Second
+)
+
+// Server is an http.Server configured by a web configuration file. The file
+// and the TLS certificates are reloaded by Run when they change, except for
+// enabling or disabling TLS and HTTP/2 which require a restart.
+type Server struct {
+	server *http.Server
+	path   string
+	tls    bool
+	http2  bool
+
+	lock    sync.RWMutex
+	version fileVersion
+	cfg     *Config
+	// tlsCfg is built from the TLS configuration tlsFrom and the files
+	// at tlsFiles.
+	tlsCfg   *tls.Config
+	tlsFrom  *TLSServerConfig
+	tlsFiles map[string]fileVersion
+
+	authLock  sync.Mutex
+	authCache map[[sha256.Size]byte]bool
+}
+
+// fileVersion identifies the content of a file without reading it.
+type fileVersion struct {
+	modTime time.Time
+	size    int64
+}
+
+func statFile(path string) (fileVersion, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileVersion{}, err
+	}
+	return fileVersion{modTime: info.ModTime(), size: info.Size()}, nil
+}
+
+// NewServer configures the given http.Server with the web configuration file
+// at path. The server is left unchanged if path is empty.
+func NewServer(server *http.Server, path string) (*Server, error) {
+	s := &Server{
+		server:    server,
+		path:      path,
+		http2:     true,
+		authCache: map[[sha256.Size]byte]bool{},
+	}
+	if path == "" {
+		return s, nil
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	err := s.loadConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	if s.cfg.HTTPServerConfig.HTTP2 != nil && !*s.cfg.HTTPServerConfig.HTTP2 {
+		s.http2 = false
+		server.TLSNextProto = map[string]func(*http.Server, *tls.Conn, http.Handler){}
+	}
+
+	if s.cfg.TLSServerConfig != nil {
+		s.tls = true
+		// Check that the certificates can be loaded before serving.
+		err := s.loadTLSConfig()
+		if err != nil {
+			return nil, errors.Wrapf(err, "configure TLS from web config file %s", path)
+		}
+		server.TLSConfig = &tls.Config{GetConfigForClient: s.tlsConfig}
+	}
+
+	server.Handler = s.handler(server.Handler)
+	return s, nil
+}
+
+// ListenAndServe listens on the address of the server and serves requests,
+// over TLS if it is configured.
+func (s *Server) ListenAndServe() error {
+	if s.tls {
+		return s.server.ListenAndServeTLS("", "")
+	}
+	return s.server.ListenAndServe()
+}
+
+// Run checks every reloadInterval whether the web configuration file or the
+// TLS certificates changed and reloads them until stopCh is closed, so that
+// they can be renewed without a restart while serving requests without
+// file I/O.
+func (s *Server) Run(stopCh <-chan struct{}) {
+	if s.path == "" {
+		return
+	}
+
+	ticker := time.NewTicker(reloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			s.reload()
+		}
+	}
+}
+
+func (s *Server) reload() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	// Errors are logged since a configuration was already loaded.
+	_ = s.loadConfig()
+	if s.tls {
+		_ = s.loadTLSConfig()
+	}
+}
+
+// loadConfig loads the web configuration file if it changed. An invalid file
+// is only an error on the first load, afterwards the previous configuration
+// is kept. It must be called with the lock held.
+func (s *Server) loadConfig() error {
+	version, err := statFile(s.path)
+	if err != nil {
+		if s.cfg == nil {
+			return errors.Wrapf(err, "stat web config file %s", s.path)
+		}
+		// The file might be in the middle of being replaced.
+		return nil
+	}
+	if s.cfg != nil && version.modTime.Equal(s.version.modTime) && version.size == s.version.size {
+		return nil
+	}
+	s.version = version
+
+	cfg, err := LoadConfig(s.path)
+	if err != nil {
+		if s.cfg == nil {
+			return err
+		}
+		klog.Errorf("failed to reload web config file, keeping the previous configuration: %v", err)
+		return nil
+	}
+
+	if s.cfg != nil {
+		if (cfg.TLSServerConfig != nil) != s.tls {
+			klog.Errorf("failed to reload web config file %s: enabling or disabling TLS requires a restart", s.path)
+			return nil
+		}
+		klog.Infof("reloaded web config file %s", s.path)
+	}
+	s.cfg = cfg
+	return nil
+}
+
+// loadTLSConfig builds the TLS configuration if the web configuration or the
+// files it references changed. An error is only returned on the first load,
+// afterwards the previous TLS configuration is kept. It must be called with
+// the lock held.
+func (s *Server) loadTLSConfig() error {
+	cfg := s.cfg.TLSServerConfig
+	files := map[string]fileVersion{}
+	for _, path := range []string{cfg.CertFile, cfg.KeyFile, cfg.ClientCAFile} {
+		if path == "" {
+			continue
+		}
+		// A missing file fails loading the TLS configuration below.
+		files[path], _ = statFile(path)
+	}
+	if s.tlsCfg != nil && cfg == s.tlsFrom && reflect.DeepEqual(files, s.tlsFiles) {
+		return nil
+	}
+
+	tlsCfg, err := cfg.tlsConfig(true)
+	if err != nil {
+		if s.tlsCfg == nil {
+			return err
+		}
+		klog.Errorf("failed to reload TLS from web config file %s, keeping the previous configuration: %v", s.path, err)
+		return nil
+	}
+	tlsCfg.NextProtos = []string{"http/1.1"}
+	if s.http2 {
+		tlsCfg.NextProtos = []string{"h2", "http/1.1"}
+	}
+	s.tlsCfg, s.tlsFrom, s.tlsFiles = tlsCfg, cfg, files
+	return nil
+}
+
+// tlsConfig returns the TLS configuration for a new connection.
+func (s *Server) tlsConfig(*tls.ClientHelloInfo) (*tls.Config, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.tlsCfg, nil
+}
+
+// handler sets the configured response headers and authenticates the
+// requests if basic auth users are configured.
+func (s *Server) handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.lock.RLock()
+		cfg := s.cfg
+		s.lock.RUnlock()
+
+		for name, value := range cfg.HTTPServerConfig.Headers {
+			w.Header().Set(name, value)
+		}
+
+		if len(cfg.Users) > 0 {
+			user, password, ok := r.BasicAuth()
+			if !ok || !s.authenticate(cfg.Users, user, password) {
+				w.Header().Set("WWW-Authenticate", "Basic")
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authenticate compares the password with the bcrypt hash of the user. The
+// results are cached as comparing bcrypt hashes is purposely slow.
+func (s *Server) authenticate(users map[string]string, user, password string) bool {
+	hash, found := users[user]
+	if !found {
+		hash = dummyHash
+	}
+
+	key := sha256.Sum256([]byte(hash + "\x00" + user + "\x00" + password))
+	s.authLock.Lock()
+	authenticated, cached := s.authCache[key]
+	s.authLock.Unlock()
+	if cached {
+		return authenticated
+	}
+
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	authenticated = found && err == nil
+
+	s.authLock.Lock()
+	if len(s.authCache) >= maxAuthCacheSize {
+		s.authCache = map[[sha256.Size]byte]bool{}
+	}
+	s.authCache[key] = authenticated
+	s.authLock.Unlock()
+
+	return authenticated
+}
@@ -0,0 +1,344 @@
+/*
+Copyright 2020 Red Hat, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package web
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// secretHash is the bcrypt hash of "secret".
+const secretHash = "$2a$10$OUqil.cAJ9/kvseNRYz1lOEk3wKAhqBrSFQsUOdwfXf50J8Q3D3yG"
+
+func TestLoadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kube-events-exporter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	testCases := []struct {
+		desc    string
+		content string
+		valid   bool
+	}{
+		{
+			desc: "Valid",
+			content: `
+tls_server_config:
+  cert_file: server.crt
+  key_file: server.key
+  client_auth_type: RequireAndVerifyClientCert
+  client_ca_file: ca.crt
+  min_version: TLS12
+  cipher_suites:
+  - TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256
+  curve_preferences:
+  - X25519
+http_server_config:
+  http2: false
+  headers:
+    X-Frame-Options: deny
+basic_auth_users:
+  alice: ` + secretHash,
+			valid: true,
+		},
+		{
+			desc: "UnknownField",
+			content: `
+basic_auth_user:
+  alice: ` + secretHash,
+			valid: false,
+		},
+		{
+			desc: "ForbiddenHeader",
+			content: `
+http_server_config:
+  headers:
+    Content-Type: text/plain
+`,
+			valid: false,
+		},
+		{
+			desc: "ClientAuthWithoutCA",
+			content: `
+tls_server_config:
+  cert_file: server.crt
+  key_file: server.key
+  client_auth_type: RequireAndVerifyClientCert
+`,
+			valid: false,
+		},
+		{
+			desc: "InvalidCipherSuite",
+			content: `
+tls_server_config:
+  cert_file: server.crt
+  key_file: server.key
+  cipher_suites:
+  - TLS_UNKNOWN
+`,
+			valid: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			path := filepath.Join(dir, tc.desc+".yaml")
+			err := ioutil.WriteFile(path, []byte(tc.content), 0600)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = LoadConfig(path)
+			if tc.valid && err != nil {
+				t.Fatalf("expected valid configuration, got %v", err)
+			}
+			if !tc.valid && err == nil {
+				t.Fatal("expected invalid configuration")
+			}
+		})
+	}
+}
+
+func newTestServer(t *testing.T, path string) (*httptest.Server, *Server) {
+	server := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+	}
+	s, err := NewServer(server, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewUnstartedServer(server.Handler)
+	if s.tls {
+		ts.TLS = server.TLSConfig
+		ts.StartTLS()
+	} else {
+		ts.Start()
+	}
+	return ts, s
+}
+
+func expectStatus(t *testing.T, client *http.Client, url, user, password string, expected int) *http.Response {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user != "" {
+		req.SetBasicAuth(user, password)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != expected {
+		t.Fatalf("expected status %d for user %q, got %d", expected, user, resp.StatusCode)
+	}
+	return resp
+}
+
+func TestServerBasicAuth(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kube-events-exporter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "web.yaml")
+	err = ioutil.WriteFile(path, []byte(`
+http_server_config:
+  headers:
+    X-Frame-Options: deny
+basic_auth_users:
+  alice: `+secretHash), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts, _ := newTestServer(t, path)
+	defer ts.Close()
+
+	testCases := []struct {
+		desc     string
+		user     string
+		password string
+		expected int
+	}{
+		{
+			desc:     "NoCredentials",
+			expected: http.StatusUnauthorized,
+		},
+		{
+			desc:     "WrongPassword",
+			user:     "alice",
+			password: "wrong",
+			expected: http.StatusUnauthorized,
+		},
+		{
+			desc:     "UnknownUser",
+			user:     "bob",
+			password: "secret",
+			expected: http.StatusUnauthorized,
+		},
+		{
+			desc:     "Authenticated",
+			user:     "alice",
+			password: "secret",
+			expected: http.StatusOK,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			resp := expectStatus(t, ts.Client(), ts.URL, tc.user, tc.password, tc.expected)
+			if got := resp.Header.Get("X-Frame-Options"); got != "deny" {
+				t.Fatalf("expected X-Frame-Options header deny, got %q", got)
+			}
+		})
+	}
+}
+
+func TestServerReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kube-events-exporter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "web.yaml")
+	modTime := time.Now()
+	writeConfig := func(content string) {
+		err := ioutil.WriteFile(path, []byte(content), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Make sure that the change is noticed whatever the precision
+		// of the file system timestamps.
+		modTime = modTime.Add(time.Minute)
+		err = os.Chtimes(path, modTime, modTime)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeConfig("basic_auth_users:\n  alice: " + secretHash)
+	ts, s := newTestServer(t, path)
+	defer ts.Close()
+	expectStatus(t, ts.Client(), ts.URL, "alice", "secret", http.StatusOK)
+
+	// Changes are only applied once the file is reloaded.
+	writeConfig("basic_auth_users:\n  bob: " + secretHash)
+	expectStatus(t, ts.Client(), ts.URL, "alice", "secret", http.StatusOK)
+	s.reload()
+	expectStatus(t, ts.Client(), ts.URL, "alice", "secret", http.StatusUnauthorized)
+	expectStatus(t, ts.Client(), ts.URL, "bob", "secret", http.StatusOK)
+
+	// An invalid file keeps the previous configuration.
+	writeConfig("basic_auth_user: {}")
+	s.reload()
+	expectStatus(t, ts.Client(), ts.URL, "bob", "secret", http.StatusOK)
+}
+
+func TestServerTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kube-events-exporter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "kube-events-exporter"},
+		DNSNames:     []string{"example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile := filepath.Join(dir, "server.crt")
+	keyFile := filepath.Join(dir, "server.key")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	err = ioutil.WriteFile(certFile, certPEM, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "web.yaml")
+	err = ioutil.WriteFile(path, []byte("tls_server_config:\n  cert_file: "+certFile+"\n  key_file: "+keyFile+"\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts, s := newTestServer(t, path)
+	defer ts.Close()
+
+	// Only trust the configured certificate, not the one of httptest.
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(certPEM)
+	client := &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: roots, ServerName: "example.com"},
+		},
+	}
+	expectStatus(t, client, ts.URL, "", "", http.StatusOK)
+
+	// The certificate isn't read on each connection and a failed reload
+	// keeps the previous one.
+	err = os.Remove(certFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.reload()
+	client.Transport.(*http.Transport).CloseIdleConnections()
+	expectStatus(t, client, ts.URL, "", "", http.StatusOK)
+}
@@ -30,33 +30,83 @@ import (
 )
 
 // NewInstrumentedEventInformer constructs a new informer for Event type with
-// instrumented list watch.
-func NewInstrumentedEventInformer(client kubernetes.Interface, namespace string, metrics *ListWatchMetrics, resyncPeriod time.Duration, indexers cache.Indexers, tweakListOptions internalinterfaces.TweakListOptionsFunc) cache.SharedIndexInformer {
+// instrumented list watch. The indexers of EventIndexers are added to the
+// given ones. If transform isn't nil, it is applied to the Events before they
+// are stored in the cache. The list requests are configured by listOptions.
+func NewInstrumentedEventInformer(client kubernetes.Interface, namespace string, metrics *ListWatchMetrics, resyncPeriod time.Duration, indexers cache.Indexers, tweakListOptions internalinterfaces.TweakListOptionsFunc, transform func(ev *v1.Event), listOptions ListOptions) cache.SharedIndexInformer {
+	eventIndexers := EventIndexers()
+	for name, indexFunc := range indexers {
+		eventIndexers[name] = indexFunc
+	}
+
+	lw := NewInstrumentedListerWatcher(
+		NewEventListerWatcher(client, namespace, tweakListOptions, listOptions),
+		metrics,
+	)
+	if transform != nil {
+		lw = NewTransformingListerWatcher(lw, transform)
+	}
+
 	return cache.NewSharedIndexInformer(
-		NewInstrumentedListerWatcher(
-			NewEventListerWatcher(client, namespace, tweakListOptions),
-			metrics,
-		),
+		lw,
 		&v1.Event{},
 		resyncPeriod,
-		indexers,
+		eventIndexers,
 	)
 }
 
-// NewEventListerWatcher constructs a new lister watcher for Event type.
-func NewEventListerWatcher(client kubernetes.Interface, namespace string, tweakListOptions internalinterfaces.TweakListOptionsFunc) cache.ListerWatcher {
+// ListOptions configures the list requests of an Event lister watcher.
+type ListOptions struct {
+	// PageSize is the number of Events requested per page, or the
+	// client-go default if it is 0.
+	PageSize int64
+	// Timeout is the timeout of each list request, or none if it is 0.
+	// Watch requests aren't affected.
+	Timeout time.Duration
+}
+
+// NewEventListerWatcher constructs a new lister watcher for Event type, listing
+// Events according to listOptions.
+func NewEventListerWatcher(client kubernetes.Interface, namespace string, tweakListOptions internalinterfaces.TweakListOptionsFunc, listOptions ListOptions) cache.ListerWatcher {
 	return &cache.ListWatch{
 		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
 			if tweakListOptions != nil {
 				tweakListOptions(&options)
 			}
+			listOptions.apply(&options)
 			return client.CoreV1().Events(namespace).List(context.TODO(), options)
 		},
 		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
 			if tweakListOptions != nil {
 				tweakListOptions(&options)
 			}
+			// Bookmarks keep the resource version of the watch recent, so
+			// that it can be restarted after a timeout without relisting
+			// the Events.
+			options.AllowWatchBookmarks = true
 			return client.CoreV1().Events(namespace).Watch(context.TODO(), options)
 		},
 	}
 }
+
+// apply applies the list options to the options of a list request made by the
+// reflector of an informer, which follows the continue tokens with the
+// client-go pager. The reflector lists at resourceVersion "0" at first, then at
+// the last synced resourceVersion. Those lists are served by the watch cache of
+// the apiserver which ignores the page size and returns all the Events at once.
+// Only the consistent lists made once the last synced resourceVersion expired
+// are served from etcd, in pages of PageSize Events. The page size replaces
+// the limit set by the pager, and the requests without a limit, such as the
+// relists at an exact resourceVersion, are left without one: only paginated
+// lists may carry a limit.
+func (o ListOptions) apply(options *metav1.ListOptions) {
+	if o.PageSize > 0 && options.Limit > 0 {
+		options.Limit = o.PageSize
+	}
+
+	if o.Timeout > 0 {
+		// The timeout is sent to the apiserver in seconds, round it up.
+		timeoutSeconds := int64((o.Timeout + time.Second - 1) / time.Second)
+		options.TimeoutSeconds = &timeoutSeconds
+	}
+}
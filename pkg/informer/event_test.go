@@ -0,0 +1,179 @@
+/*
+Copyright 2020 Red Hat, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package informer
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestListOptionsPageSize(t *testing.T) {
+	testCases := []struct {
+		desc         string
+		options      metav1.ListOptions
+		listPageSize int64
+		expected     int64
+	}{
+		{
+			desc:         "DefaultPageSize",
+			options:      metav1.ListOptions{ResourceVersion: "0", Limit: 500},
+			listPageSize: 0,
+			expected:     500,
+		},
+		{
+			// Consistent lists are paginated by etcd.
+			desc:         "PageSize",
+			options:      metav1.ListOptions{ResourceVersion: "", Limit: 500},
+			listPageSize: 100,
+			expected:     100,
+		},
+		{
+			desc:         "ContinuePage",
+			options:      metav1.ListOptions{Continue: "token", Limit: 500},
+			listPageSize: 100,
+			expected:     100,
+		},
+		{
+			// Unpaginated lists can be served from the watch cache.
+			desc:         "Unpaginated",
+			options:      metav1.ListOptions{ResourceVersion: "42"},
+			listPageSize: 100,
+			expected:     0,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+			ListOptions{PageSize: tc.listPageSize}.apply(&tc.options)
+			if tc.options.Limit != tc.expected {
+				t.Fatalf("expected limit %d, got %d", tc.expected, tc.options.Limit)
+			}
+		})
+	}
+}
+
+func TestListOptionsTimeout(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		timeout  time.Duration
+		expected *int64
+	}{
+		{
+			desc: "NoTimeout",
+		},
+		{
+			desc:     "Seconds",
+			timeout:  30 * time.Second,
+			expected: int64Ptr(30),
+		},
+		{
+			desc:     "RoundedUp",
+			timeout:  1500 * time.Millisecond,
+			expected: int64Ptr(2),
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+			options := metav1.ListOptions{}
+			ListOptions{Timeout: tc.timeout}.apply(&options)
+			if !reflect.DeepEqual(options.TimeoutSeconds, tc.expected) {
+				t.Fatalf("expected timeout %v, got %v", tc.expected, options.TimeoutSeconds)
+			}
+		})
+	}
+}
+
+func int64Ptr(i int64) *int64 {
+	return &i
+}
+
+func TestEventInformerWatchExpiry(t *testing.T) {
+	ev := &v1.Event{
+		ObjectMeta: metav1.ObjectMeta{Name: "event", Namespace: "default", ResourceVersion: "1"},
+		Count:      1,
+	}
+	client := fake.NewSimpleClientset(ev)
+	watchers := make(chan *watch.FakeWatcher, 2)
+	client.PrependWatchReactor("events", func(clienttesting.Action) (bool, watch.Interface, error) {
+		w := watch.NewFake()
+		watchers <- w
+		return true, w, nil
+	})
+
+	metrics := NewListWatchMetrics(prometheus.NewRegistry())
+	inf := NewInstrumentedEventInformer(client, metav1.NamespaceAll, metrics, 0, cache.Indexers{}, nil, nil, ListOptions{})
+	updates := make(chan [2]*v1.Event, 1)
+	inf.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			updates <- [2]*v1.Event{oldObj.(*v1.Event), newObj.(*v1.Event)}
+		},
+	})
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go inf.Run(stopCh)
+
+	w := <-watchers
+	w.Action(watch.Bookmark, &v1.Event{ObjectMeta: metav1.ObjectMeta{ResourceVersion: "2"}})
+
+	// The Event occurs again while the resource version of the watch
+	// expires.
+	updated := ev.DeepCopy()
+	updated.ResourceVersion = "3"
+	updated.Count = 5
+	err := client.Tracker().Update(v1.SchemeGroupVersion.WithResource("events"), updated, ev.Namespace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Error(&metav1.Status{
+		Status: metav1.StatusFailure,
+		Code:   410,
+		Reason: metav1.StatusReasonExpired,
+	})
+
+	// The relist replays the update of the Event from its cached version.
+	select {
+	case update := <-updates:
+		if update[0].Count != 1 || update[1].Count != 5 {
+			t.Fatalf("expected update from count 1 to 5, got %d to %d", update[0].Count, update[1].Count)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for relist")
+	}
+
+	if got := testutil.ToFloat64(metrics.relistTotal.WithLabelValues(RelistReasonExpired)); got != 1 {
+		t.Fatalf("expected 1 relist after expiry, got %v", got)
+	}
+	if got := testutil.ToFloat64(metrics.bookmarksTotal); got != 1 {
+		t.Fatalf("expected 1 bookmark, got %v", got)
+	}
+}
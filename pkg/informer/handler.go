@@ -0,0 +1,98 @@
+/*
+Copyright 2020 Red Hat, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package informer
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// DeletedEvent returns the Event of a deletion notification, which is a
+// cache.DeletedFinalStateUnknown tombstone holding the last known state of the
+// Event if the deletion was missed while the watch was down. The first boolean
+// is true for a tombstone, the second one is false if obj isn't an Event.
+func DeletedEvent(obj interface{}) (*v1.Event, bool, bool) {
+	if ev, ok := obj.(*v1.Event); ok {
+		return ev, false, true
+	}
+	tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+	if !ok {
+		return nil, false, false
+	}
+	ev, ok := tombstone.Obj.(*v1.Event)
+	return ev, true, ok
+}
+
+// NewCachedEventsHandler returns a handler tracking the number of Events stored
+// in informer caches in the given gauge, partitioned by type and namespace
+// labels. The series are deleted once their Events are all deleted.
+func NewCachedEventsHandler(gauge *prometheus.GaugeVec) cache.ResourceEventHandler {
+	h := &cachedEventsHandler{
+		gauge:  gauge,
+		counts: map[cachedEventsKey]int{},
+	}
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			if ev, ok := obj.(*v1.Event); ok {
+				h.add(ev, 1)
+			}
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldEv, oldOK := oldObj.(*v1.Event)
+			newEv, newOK := newObj.(*v1.Event)
+			// An Event created again with the same name while the watch
+			// was down can have a different type.
+			if oldOK && newOK && oldEv.Type != newEv.Type {
+				h.add(oldEv, -1)
+				h.add(newEv, 1)
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			if ev, _, ok := DeletedEvent(obj); ok {
+				h.add(ev, -1)
+			}
+		},
+	}
+}
+
+type cachedEventsKey struct {
+	eventType string
+	namespace string
+}
+
+type cachedEventsHandler struct {
+	lock   sync.Mutex
+	gauge  *prometheus.GaugeVec
+	counts map[cachedEventsKey]int
+}
+
+func (h *cachedEventsHandler) add(ev *v1.Event, delta int) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	key := cachedEventsKey{eventType: ev.Type, namespace: ev.Namespace}
+	h.counts[key] += delta
+	if h.counts[key] <= 0 {
+		delete(h.counts, key)
+		h.gauge.DeleteLabelValues(key.eventType, key.namespace)
+		return
+	}
+	h.gauge.WithLabelValues(key.eventType, key.namespace).Set(float64(h.counts[key]))
+}
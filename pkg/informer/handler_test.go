@@ -0,0 +1,103 @@
+/*
+Copyright 2020 Red Hat, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package informer
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestDeletedEvent(t *testing.T) {
+	ev := &v1.Event{ObjectMeta: metav1.ObjectMeta{Name: "event", Namespace: "default"}}
+
+	testCases := []struct {
+		desc      string
+		obj       interface{}
+		event     *v1.Event
+		tombstone bool
+		ok        bool
+	}{
+		{
+			desc:  "Event",
+			obj:   ev,
+			event: ev,
+			ok:    true,
+		},
+		{
+			desc:      "Tombstone",
+			obj:       cache.DeletedFinalStateUnknown{Key: "default/event", Obj: ev},
+			event:     ev,
+			tombstone: true,
+			ok:        true,
+		},
+		{
+			desc:      "TombstoneNotEvent",
+			obj:       cache.DeletedFinalStateUnknown{Key: "default/pod", Obj: &v1.Pod{}},
+			tombstone: true,
+		},
+		{
+			desc: "NotEvent",
+			obj:  &v1.Pod{},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+			event, tombstone, ok := DeletedEvent(tc.obj)
+			if event != tc.event || tombstone != tc.tombstone || ok != tc.ok {
+				t.Fatalf("expected (%v, %t, %t), got (%v, %t, %t)", tc.event, tc.tombstone, tc.ok, event, tombstone, ok)
+			}
+		})
+	}
+}
+
+func TestCachedEventsHandler(t *testing.T) {
+	gauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "cached_events"}, []string{"type", "namespace"})
+	handler := NewCachedEventsHandler(gauge)
+
+	newEvent := func(name, evType, namespace string) *v1.Event {
+		return &v1.Event{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+			Type:       evType,
+		}
+	}
+	warning := newEvent("warning", v1.EventTypeWarning, "default")
+	normal := newEvent("normal", v1.EventTypeNormal, "default")
+	other := newEvent("other", v1.EventTypeWarning, "kube-system")
+
+	handler.OnAdd(warning)
+	handler.OnAdd(normal)
+	handler.OnAdd(other)
+	handler.OnUpdate(normal, newEvent("normal", v1.EventTypeWarning, "default"))
+	if got := testutil.ToFloat64(gauge.WithLabelValues(v1.EventTypeWarning, "default")); got != 2 {
+		t.Fatalf("expected 2 cached warning events, got %v", got)
+	}
+
+	// The series of the deleted Events are deleted.
+	handler.OnDelete(other)
+	handler.OnDelete(cache.DeletedFinalStateUnknown{Key: "default/warning", Obj: warning})
+	if got := testutil.CollectAndCount(gauge); got != 1 {
+		t.Fatalf("expected 1 series, got %d", got)
+	}
+}
@@ -0,0 +1,188 @@
+/*
+Copyright 2020 Red Hat, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package informer
+
+import (
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Names of the indexes of the Event informers.
+const (
+	NamespaceIndex          = cache.NamespaceIndex
+	InvolvedObjectUIDIndex  = "involvedObject.uid"
+	InvolvedObjectIndex     = "involvedObject"
+	InvolvedObjectKindIndex = "involvedObject.kind"
+	ReasonIndex             = "reason"
+)
+
+// EventIndexers returns the indexers added to the Event informers to look up
+// Events without scanning their whole cache.
+func EventIndexers() cache.Indexers {
+	return cache.Indexers{
+		NamespaceIndex: cache.MetaNamespaceIndexFunc,
+		InvolvedObjectUIDIndex: eventIndexFunc(func(ev *v1.Event) string {
+			return string(ev.InvolvedObject.UID)
+		}),
+		InvolvedObjectIndex: eventIndexFunc(func(ev *v1.Event) string {
+			return involvedObjectKey(ev.InvolvedObject.Kind, ev.InvolvedObject.Namespace, ev.InvolvedObject.Name)
+		}),
+		InvolvedObjectKindIndex: eventIndexFunc(func(ev *v1.Event) string {
+			return ev.InvolvedObject.Kind
+		}),
+		ReasonIndex: eventIndexFunc(func(ev *v1.Event) string {
+			return ev.Reason
+		}),
+	}
+}
+
+func eventIndexFunc(field func(ev *v1.Event) string) cache.IndexFunc {
+	return func(obj interface{}) ([]string, error) {
+		ev, ok := obj.(*v1.Event)
+		if !ok {
+			return nil, fmt.Errorf("expected *v1.Event, got %T", obj)
+		}
+		return []string{field(ev)}, nil
+	}
+}
+
+func involvedObjectKey(kind, namespace, name string) string {
+	return kind + "/" + namespace + "/" + name
+}
+
+// Selector selects Events by their involved object, reason and type. Empty
+// fields match all Events.
+type Selector struct {
+	Namespace string
+	Kind      string
+	Name      string
+	Reason    string
+	Type      string
+}
+
+// Matches returns whether the Event is selected.
+func (s Selector) Matches(ev *v1.Event) bool {
+	return matches(s.Namespace, ev.InvolvedObject.Namespace) &&
+		matches(s.Kind, ev.InvolvedObject.Kind) &&
+		matches(s.Name, ev.InvolvedObject.Name) &&
+		matches(s.Reason, ev.Reason) &&
+		matches(s.Type, ev.Type)
+}
+
+func matches(selected, value string) bool {
+	return selected == "" || selected == value
+}
+
+// index returns the most selective index usable to list a superset of the
+// selected Events, or an empty index name if none is.
+func (s Selector) index() (string, string) {
+	switch {
+	case s.Kind != "" && s.Namespace != "" && s.Name != "":
+		return InvolvedObjectIndex, involvedObjectKey(s.Kind, s.Namespace, s.Name)
+	case s.Reason != "":
+		return ReasonIndex, s.Reason
+	case s.Kind != "":
+		return InvolvedObjectKindIndex, s.Kind
+	case s.Namespace != "":
+		// The Events of namespaced objects are in the namespace of
+		// the object.
+		return NamespaceIndex, s.Namespace
+	default:
+		return "", ""
+	}
+}
+
+// ClusterEvent is an Event listed from the cache of a cluster. Cluster is
+// only set when Events are collected from several clusters.
+type ClusterEvent struct {
+	Cluster string `json:"cluster,omitempty"`
+	*v1.Event
+}
+
+// EventLister looks up Events in the cache of an Event informer, created with
+// the indexers of EventIndexers.
+type EventLister struct {
+	indexer cache.Indexer
+}
+
+// NewEventLister returns a new EventLister of the Events in the indexer.
+func NewEventLister(indexer cache.Indexer) *EventLister {
+	return &EventLister{indexer: indexer}
+}
+
+// List returns the Events matching the selector.
+func (l *EventLister) List(selector Selector) ([]*v1.Event, error) {
+	var objs []interface{}
+	indexName, indexedValue := selector.index()
+	if indexName == "" {
+		objs = l.indexer.List()
+	} else {
+		var err error
+		objs, err = l.indexer.ByIndex(indexName, indexedValue)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	events := make([]*v1.Event, 0, len(objs))
+	for _, obj := range objs {
+		ev, ok := obj.(*v1.Event)
+		if ok && selector.Matches(ev) {
+			events = append(events, ev)
+		}
+	}
+	return events, nil
+}
+
+// ByNamespace returns the Events in the namespace.
+func (l *EventLister) ByNamespace(namespace string) ([]*v1.Event, error) {
+	return l.byIndex(NamespaceIndex, namespace)
+}
+
+// ByInvolvedObjectUID returns the Events about the object with the given UID.
+func (l *EventLister) ByInvolvedObjectUID(uid types.UID) ([]*v1.Event, error) {
+	return l.byIndex(InvolvedObjectUIDIndex, string(uid))
+}
+
+// ByInvolvedObject returns the Events about the object with the given kind,
+// namespace and name. The namespace is empty for cluster-scoped objects.
+func (l *EventLister) ByInvolvedObject(kind, namespace, name string) ([]*v1.Event, error) {
+	return l.byIndex(InvolvedObjectIndex, involvedObjectKey(kind, namespace, name))
+}
+
+// ByReason returns the Events with the given reason.
+func (l *EventLister) ByReason(reason string) ([]*v1.Event, error) {
+	return l.byIndex(ReasonIndex, reason)
+}
+
+func (l *EventLister) byIndex(indexName, indexedValue string) ([]*v1.Event, error) {
+	objs, err := l.indexer.ByIndex(indexName, indexedValue)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]*v1.Event, 0, len(objs))
+	for _, obj := range objs {
+		if ev, ok := obj.(*v1.Event); ok {
+			events = append(events, ev)
+		}
+	}
+	return events, nil
+}
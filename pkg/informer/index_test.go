@@ -0,0 +1,209 @@
+/*
+Copyright 2020 Red Hat, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package informer
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+)
+
+func newTestEvent(i int) *v1.Event {
+	pod := fmt.Sprintf("pod-%d", i/10)
+	namespace := fmt.Sprintf("namespace-%d", i%100)
+	reason := "Pulled"
+	if i%1000 == 0 {
+		reason = "BackOff"
+	}
+	return &v1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s.%d", pod, i),
+			Namespace: namespace,
+		},
+		InvolvedObject: v1.ObjectReference{
+			Kind:      "Pod",
+			Namespace: namespace,
+			Name:      pod,
+			UID:       types.UID(namespace + "/" + pod),
+		},
+		Reason: reason,
+		Type:   v1.EventTypeNormal,
+	}
+}
+
+func newTestIndexer(b testing.TB, size int) cache.Indexer {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, EventIndexers())
+	for i := 0; i < size; i++ {
+		err := indexer.Add(newTestEvent(i))
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+	return indexer
+}
+
+func eventNames(events []*v1.Event) []string {
+	names := make([]string, 0, len(events))
+	for _, ev := range events {
+		names = append(names, ev.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestEventLister(t *testing.T) {
+	lister := NewEventLister(newTestIndexer(t, 2000))
+
+	testCases := []struct {
+		desc     string
+		list     func() ([]*v1.Event, error)
+		expected int
+	}{
+		{
+			desc:     "ByNamespace",
+			list:     func() ([]*v1.Event, error) { return lister.ByNamespace("namespace-1") },
+			expected: 20,
+		},
+		{
+			desc:     "ByInvolvedObjectUID",
+			list:     func() ([]*v1.Event, error) { return lister.ByInvolvedObjectUID("namespace-1/pod-10") },
+			expected: 1,
+		},
+		{
+			desc:     "ByInvolvedObject",
+			list:     func() ([]*v1.Event, error) { return lister.ByInvolvedObject("Pod", "namespace-1", "pod-10") },
+			expected: 1,
+		},
+		{
+			desc:     "ByReason",
+			list:     func() ([]*v1.Event, error) { return lister.ByReason("BackOff") },
+			expected: 2,
+		},
+		{
+			desc: "ListByInvolvedObject",
+			list: func() ([]*v1.Event, error) {
+				return lister.List(Selector{Kind: "Pod", Namespace: "namespace-1", Name: "pod-10"})
+			},
+			expected: 1,
+		},
+		{
+			desc:     "ListByName",
+			list:     func() ([]*v1.Event, error) { return lister.List(Selector{Name: "pod-10"}) },
+			expected: 10,
+		},
+		{
+			desc: "ListByReasonAndType",
+			list: func() ([]*v1.Event, error) {
+				return lister.List(Selector{Reason: "BackOff", Type: v1.EventTypeWarning})
+			},
+			expected: 0,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+			events, err := tc.list()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(events) != tc.expected {
+				t.Fatalf("expected %d events, got %d: %v", tc.expected, len(events), eventNames(events))
+			}
+		})
+	}
+}
+
+func TestSelectorIndex(t *testing.T) {
+	indexer := newTestIndexer(t, 2000)
+	lister := NewEventLister(indexer)
+
+	// Listing through the indexes returns the same Events as scanning the
+	// whole cache.
+	for _, selector := range []Selector{
+		{Kind: "Pod", Namespace: "namespace-1", Name: "pod-10"},
+		{Reason: "BackOff"},
+		{Kind: "Pod", Name: "pod-10"},
+		{Namespace: "namespace-1"},
+	} {
+		events, err := lister.List(selector)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var expected []*v1.Event
+		for _, obj := range indexer.List() {
+			if ev := obj.(*v1.Event); selector.Matches(ev) {
+				expected = append(expected, ev)
+			}
+		}
+		got, want := eventNames(events), eventNames(expected)
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Fatalf("expected events %v for selector %+v, got %v", want, selector, got)
+		}
+	}
+}
+
+func BenchmarkEventLister(b *testing.B) {
+	lister := NewEventLister(newTestIndexer(b, 100000))
+
+	benchmarks := []struct {
+		desc string
+		list func() ([]*v1.Event, error)
+	}{
+		{
+			desc: "ByInvolvedObjectUID",
+			list: func() ([]*v1.Event, error) { return lister.ByInvolvedObjectUID("namespace-1/pod-10") },
+		},
+		{
+			desc: "ByInvolvedObject",
+			list: func() ([]*v1.Event, error) { return lister.ByInvolvedObject("Pod", "namespace-1", "pod-10") },
+		},
+		{
+			desc: "ByNamespace",
+			list: func() ([]*v1.Event, error) { return lister.ByNamespace("namespace-1") },
+		},
+		{
+			desc: "ByReason",
+			list: func() ([]*v1.Event, error) { return lister.ByReason("BackOff") },
+		},
+		{
+			// Scan the whole cache, for comparison.
+			desc: "ListAll",
+			list: func() ([]*v1.Event, error) { return lister.List(Selector{Type: v1.EventTypeWarning}) },
+		},
+	}
+
+	for _, bm := range benchmarks {
+		bm := bm
+		b.Run(bm.desc, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				_, err := bm.list()
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
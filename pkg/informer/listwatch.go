@@ -17,24 +17,34 @@ limitations under the License.
 package informer
 
 import (
+	"net"
+	"sync"
+	"time"
+
 	"github.com/prometheus/client_golang/prometheus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/tools/cache"
 )
 
-// ListWatchMetrics stores the pointers of list/watch counter metrics.
+// ListWatchMetrics stores the pointers of list/watch metrics.
 type ListWatchMetrics struct {
 	listTotal        prometheus.Counter
 	listFailedTotal  prometheus.Counter
+	listPagesTotal   prometheus.Counter
+	listDuration     prometheus.Histogram
 	watchTotal       prometheus.Counter
 	watchFailedTotal prometheus.Counter
+	bookmarksTotal   prometheus.Counter
+	relistTotal      *prometheus.CounterVec
 }
 
-// NewListWatchMetrics takes in a prometheus registry and initializes and
+// NewListWatchMetrics takes in a prometheus registerer and initializes and
 // registers list and watch metrics.
-func NewListWatchMetrics(registry *prometheus.Registry) *ListWatchMetrics {
+func NewListWatchMetrics(registry prometheus.Registerer) *ListWatchMetrics {
 	metrics := &ListWatchMetrics{
 		listTotal: prometheus.NewCounter(
 			prometheus.CounterOpts{
@@ -48,6 +58,19 @@ func NewListWatchMetrics(registry *prometheus.Registry) *ListWatchMetrics {
 				Help: "Number of times a list operation failed",
 			},
 		),
+		listPagesTotal: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Name: "kube_events_exporter_list_pages_total",
+				Help: "Number of pages received by list operations",
+			},
+		),
+		listDuration: prometheus.NewHistogram(
+			prometheus.HistogramOpts{
+				Name:    "kube_events_exporter_list_duration_seconds",
+				Help:    "Duration of the list requests, each page being a request",
+				Buckets: prometheus.ExponentialBuckets(0.05, 2, 10),
+			},
+		),
 		watchTotal: prometheus.NewCounter(
 			prometheus.CounterOpts{
 				Name: "kube_events_exporter_watch_total",
@@ -60,21 +83,63 @@ func NewListWatchMetrics(registry *prometheus.Registry) *ListWatchMetrics {
 				Help: "Number of times a watch operation failed",
 			},
 		),
+		bookmarksTotal: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Name: "kube_events_exporter_watch_bookmarks_total",
+				Help: "Number of bookmarks received by watch operations",
+			},
+		),
+		relistTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "kube_events_exporter_relist_total",
+				Help: "Number of times the Events were listed again after a failure, by reason of the failure",
+			},
+			[]string{"reason"},
+		),
 	}
 	registry.MustRegister(
 		metrics.listTotal,
 		metrics.listFailedTotal,
+		metrics.listPagesTotal,
+		metrics.listDuration,
 		metrics.watchTotal,
 		metrics.watchFailedTotal,
+		metrics.bookmarksTotal,
+		metrics.relistTotal,
 	)
 	return metrics
 }
 
+// Reasons of the relists, see InstrumentedListerWatcher.
+const (
+	// RelistReasonExpired is the reason of the relists after the resource
+	// version of a watch or list expired, with the HTTP status 410 Gone.
+	RelistReasonExpired = "expired"
+	// RelistReasonNetwork is the reason of the relists after a watch or
+	// list failed to reach the apiserver or was interrupted.
+	RelistReasonNetwork = "network"
+	// RelistReasonError is the reason of the relists after a watch or list
+	// failed with another error.
+	RelistReasonError = "error"
+	// RelistReasonUnknown is the reason of the relists after a watch
+	// closed without error, which the reflector considers as a failure
+	// when it lasted less than a second without any Event.
+	RelistReasonUnknown = "unknown"
+)
+
 // InstrumentedListerWatcher provides the list/watch metrics with a cache
-// ListerWatcher obj and the related resource.
+// ListerWatcher obj and the related resource. The reflector of an informer
+// lists the Events once before watching them, and lists them again, which is
+// counted as a relist, every time a watch or list fails.
 type InstrumentedListerWatcher struct {
 	lw      cache.ListerWatcher
 	metrics *ListWatchMetrics
+
+	lock sync.Mutex
+	// listed is true once the Events were listed a first time.
+	listed bool
+	// lastErr is the error of the last failed watch or list, if any.
+	lastErr error
 }
 
 // NewInstrumentedListerWatcher returns a new InstrumentedListerWatcher.
@@ -90,12 +155,20 @@ func NewInstrumentedListerWatcher(lw cache.ListerWatcher, metrics *ListWatchMetr
 // operation it instruments.
 func (i *InstrumentedListerWatcher) List(options metav1.ListOptions) (runtime.Object, error) {
 	i.metrics.listTotal.Inc()
+	if options.Continue == "" {
+		i.countRelist()
+	}
 
+	start := time.Now()
 	res, err := i.lw.List(options)
+	i.metrics.listDuration.Observe(time.Since(start).Seconds())
 	if err != nil {
 		i.metrics.listFailedTotal.Inc()
+		i.setLastErr(err)
 		return nil, err
 	}
+	i.metrics.listPagesTotal.Inc()
+	i.setLastErr(nil)
 
 	return res, nil
 }
@@ -109,8 +182,55 @@ func (i *InstrumentedListerWatcher) Watch(options metav1.ListOptions) (watch.Int
 	res, err := i.lw.Watch(options)
 	if err != nil {
 		i.metrics.watchFailedTotal.Inc()
+		i.setLastErr(err)
 		return nil, err
 	}
+	if res == nil {
+		return nil, nil
+	}
 
-	return res, nil
+	return watch.Filter(res, func(event watch.Event) (watch.Event, bool) {
+		switch event.Type {
+		case watch.Bookmark:
+			i.metrics.bookmarksTotal.Inc()
+		case watch.Error:
+			i.setLastErr(apierrors.FromObject(event.Object))
+		}
+		return event, true
+	}), nil
+}
+
+// countRelist counts a list made after the first one, by reason of the last
+// failure.
+func (i *InstrumentedListerWatcher) countRelist() {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	if !i.listed {
+		i.listed = true
+		return
+	}
+	i.metrics.relistTotal.WithLabelValues(relistReason(i.lastErr)).Inc()
+}
+
+func (i *InstrumentedListerWatcher) setLastErr(err error) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	i.lastErr = err
+}
+
+// relistReason returns the reason of a relist caused by err.
+func relistReason(err error) string {
+	switch {
+	case err == nil:
+		return RelistReasonUnknown
+	case apierrors.IsResourceExpired(err) || apierrors.IsGone(err):
+		return RelistReasonExpired
+	case utilnet.IsConnectionRefused(err) || utilnet.IsConnectionReset(err) || utilnet.IsProbableEOF(err):
+		return RelistReasonNetwork
+	}
+	if _, ok := err.(net.Error); ok {
+		return RelistReasonNetwork
+	}
+	return RelistReasonError
 }
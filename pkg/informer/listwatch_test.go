@@ -18,11 +18,15 @@ package informer
 
 import (
 	"errors"
+	"net"
+	"syscall"
 	"testing"
 
 	"github.com/prometheus/client_golang/prometheus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/tools/cache"
 )
@@ -30,6 +34,7 @@ import (
 const (
 	listTotal        = "kube_events_exporter_list_total"
 	listFailedTotal  = "kube_events_exporter_list_failed_total"
+	listPagesTotal   = "kube_events_exporter_list_pages_total"
 	watchTotal       = "kube_events_exporter_watch_total"
 	watchFailedTotal = "kube_events_exporter_watch_failed_total"
 )
@@ -80,6 +85,14 @@ func TestInstrumentedListerWatcher(t *testing.T) {
 			count:      1,
 			f:          func() { _, _ = errorLW.List(metav1.ListOptions{}) },
 		},
+		{
+			// The page listed by ListSuccess is counted as well.
+			desc:       "ListPages",
+			lw:         successLW,
+			metricName: listPagesTotal,
+			count:      2,
+			f:          func() { _, _ = successLW.List(metav1.ListOptions{Continue: "token"}) },
+		},
 		{
 			desc:       "WatchSuccess",
 			lw:         successLW,
@@ -119,3 +132,47 @@ func TestInstrumentedListerWatcher(t *testing.T) {
 		})
 	}
 }
+
+func TestRelistReason(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		err      error
+		expected string
+	}{
+		{
+			desc:     "NoError",
+			expected: RelistReasonUnknown,
+		},
+		{
+			desc:     "Expired",
+			err:      apierrors.NewResourceExpired("too old resource version"),
+			expected: RelistReasonExpired,
+		},
+		{
+			desc:     "Gone",
+			err:      apierrors.NewGone("gone"),
+			expected: RelistReasonExpired,
+		},
+		{
+			desc:     "ConnectionRefused",
+			err:      &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED},
+			expected: RelistReasonNetwork,
+		},
+		{
+			desc:     "Forbidden",
+			err:      apierrors.NewForbidden(schema.GroupResource{Resource: "events"}, "", errors.New("")),
+			expected: RelistReasonError,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+			reason := relistReason(tc.err)
+			if reason != tc.expected {
+				t.Fatalf("expected reason %q, got %q", tc.expected, reason)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+/*
+Copyright 2020 Red Hat, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package informer
+
+import (
+	"k8s.io/client-go/tools/cache"
+)
+
+// Status describes the state of an informer.
+type Status struct {
+	// Cluster is the name of the cluster watched by the informer when
+	// Events are collected from several clusters. It isn't set by
+	// GetStatus.
+	Cluster             string `json:"cluster,omitempty"`
+	FieldSelector       string `json:"fieldSelector"`
+	CacheSize           int    `json:"cacheSize"`
+	LastResourceVersion string `json:"lastResourceVersion"`
+	Synced              bool   `json:"synced"`
+}
+
+// GetStatus returns the status of an informer listing and watching the objects
+// matching fieldSelector.
+func GetStatus(informer cache.SharedIndexInformer, fieldSelector string) Status {
+	return Status{
+		FieldSelector:       fieldSelector,
+		CacheSize:           len(informer.GetStore().ListKeys()),
+		LastResourceVersion: informer.LastSyncResourceVersion(),
+		Synced:              informer.HasSynced(),
+	}
+}
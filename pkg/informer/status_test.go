@@ -0,0 +1,68 @@
+/*
+Copyright 2020 Red Hat, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package informer
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestGetStatus(t *testing.T) {
+	lw := &cache.ListWatch{
+		ListFunc: func(metav1.ListOptions) (runtime.Object, error) {
+			return &v1.EventList{
+				ListMeta: metav1.ListMeta{ResourceVersion: "42"},
+				Items: []v1.Event{
+					{ObjectMeta: metav1.ObjectMeta{Name: "first", Namespace: "default"}},
+					{ObjectMeta: metav1.ObjectMeta{Name: "second", Namespace: "default"}},
+				},
+			}, nil
+		},
+		WatchFunc: func(metav1.ListOptions) (watch.Interface, error) {
+			return watch.NewFake(), nil
+		},
+	}
+	informer := cache.NewSharedIndexInformer(lw, &v1.Event{}, 0, cache.Indexers{})
+
+	status := GetStatus(informer, "type=Warning")
+	if status.Synced {
+		t.Fatal("expected informer not to be synced before running")
+	}
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go informer.Run(stopCh)
+	if !cache.WaitForCacheSync(stopCh, informer.HasSynced) {
+		t.Fatal("timed out waiting for informer to sync")
+	}
+
+	status = GetStatus(informer, "type=Warning")
+	expected := Status{
+		FieldSelector:       "type=Warning",
+		CacheSize:           2,
+		LastResourceVersion: "42",
+		Synced:              true,
+	}
+	if status != expected {
+		t.Fatalf("expected status %+v, got %+v", expected, status)
+	}
+}
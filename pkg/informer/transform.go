@@ -0,0 +1,151 @@
+/*
+Copyright 2020 Red Hat, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package informer
+
+import (
+	"unicode/utf8"
+
+	"github.com/prometheus/client_golang/prometheus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+// EventTransform reduces the memory used by the Events stored in an informer
+// cache.
+type EventTransform struct {
+	// DropUnusedFields drops the metadata fields which are never used by
+	// the exporter: managedFields, ownerReferences, finalizers, selfLink
+	// and generateName.
+	DropUnusedFields bool
+	DropAnnotations  bool
+	DropLabels       bool
+	// MaxMessageLength is the maximum length of the messages in bytes.
+	// Messages aren't truncated if it is 0.
+	MaxMessageLength int
+}
+
+// Transform transforms the Event in place.
+func (t EventTransform) Transform(ev *v1.Event) {
+	if t.DropUnusedFields {
+		ev.ManagedFields = nil
+		ev.OwnerReferences = nil
+		ev.Finalizers = nil
+		ev.SelfLink = ""
+		ev.GenerateName = ""
+	}
+	if t.DropAnnotations {
+		ev.Annotations = nil
+	}
+	if t.DropLabels {
+		ev.Labels = nil
+	}
+	if t.MaxMessageLength > 0 && len(ev.Message) > t.MaxMessageLength {
+		ev.Message = truncate(ev.Message, t.MaxMessageLength)
+	}
+}
+
+// truncate truncates s to at most n bytes without splitting a rune.
+func truncate(s string, n int) string {
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	// Copy the truncated message so that the original one can be garbage
+	// collected.
+	return string([]byte(s[:n]))
+}
+
+// TransformingListerWatcher is a ListerWatcher transforming the listed and
+// watched Events before they are stored in an informer cache.
+type TransformingListerWatcher struct {
+	lw        cache.ListerWatcher
+	transform func(ev *v1.Event)
+}
+
+// NewTransformingListerWatcher returns a ListerWatcher applying transform to
+// the Events returned by lw.
+func NewTransformingListerWatcher(lw cache.ListerWatcher, transform func(ev *v1.Event)) cache.ListerWatcher {
+	return &TransformingListerWatcher{
+		lw:        lw,
+		transform: transform,
+	}
+}
+
+// List implements the cache.Lister interface.
+func (t *TransformingListerWatcher) List(options metav1.ListOptions) (runtime.Object, error) {
+	list, err := t.lw.List(options)
+	if err != nil {
+		return nil, err
+	}
+
+	err = meta.EachListItem(list, func(obj runtime.Object) error {
+		t.transformObject(obj)
+		return nil
+	})
+	return list, err
+}
+
+// Watch implements the cache.Watcher interface.
+func (t *TransformingListerWatcher) Watch(options metav1.ListOptions) (watch.Interface, error) {
+	w, err := t.lw.Watch(options)
+	if err != nil {
+		return nil, err
+	}
+
+	return watch.Filter(w, func(in watch.Event) (watch.Event, bool) {
+		t.transformObject(in.Object)
+		return in, true
+	}), nil
+}
+
+func (t *TransformingListerWatcher) transformObject(obj runtime.Object) {
+	// Watch errors carry a metav1.Status instead of an Event.
+	if ev, ok := obj.(*v1.Event); ok {
+		t.transform(ev)
+	}
+}
+
+// NewCacheSizeHandler returns a handler tracking the approximate size in
+// bytes of the Events stored in an informer cache in the given gauge. The
+// size of an Event is the size of its protobuf encoding.
+func NewCacheSizeHandler(gauge prometheus.Gauge) cache.ResourceEventHandler {
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			gauge.Add(eventSize(obj))
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			gauge.Add(eventSize(newObj) - eventSize(oldObj))
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			gauge.Sub(eventSize(obj))
+		},
+	}
+}
+
+func eventSize(obj interface{}) float64 {
+	ev, ok := obj.(*v1.Event)
+	if !ok {
+		return 0
+	}
+	return float64(ev.Size())
+}
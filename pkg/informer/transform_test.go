@@ -0,0 +1,159 @@
+/*
+Copyright 2020 Red Hat, Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package informer
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+func newTransformTestEvent() *v1.Event {
+	return &v1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:          "pod.1",
+			Namespace:     "default",
+			GenerateName:  "pod.",
+			Labels:        map[string]string{"app": "web"},
+			Annotations:   map[string]string{"note": "value"},
+			ManagedFields: []metav1.ManagedFieldsEntry{{Manager: "kubelet"}},
+		},
+		Message: "Back-off pulling image \"café\"",
+	}
+}
+
+func TestEventTransform(t *testing.T) {
+	testCases := []struct {
+		desc      string
+		transform EventTransform
+		check     func(ev *v1.Event) bool
+	}{
+		{
+			desc:      "DropUnusedFields",
+			transform: EventTransform{DropUnusedFields: true},
+			check: func(ev *v1.Event) bool {
+				return ev.ManagedFields == nil && ev.GenerateName == "" && ev.Labels != nil && ev.Annotations != nil
+			},
+		},
+		{
+			desc:      "DropAnnotationsAndLabels",
+			transform: EventTransform{DropAnnotations: true, DropLabels: true},
+			check: func(ev *v1.Event) bool {
+				return ev.Annotations == nil && ev.Labels == nil && ev.ManagedFields != nil
+			},
+		},
+		{
+			desc:      "TruncateMessage",
+			transform: EventTransform{MaxMessageLength: 8},
+			check: func(ev *v1.Event) bool {
+				return ev.Message == "Back-off"
+			},
+		},
+		{
+			// The é of café is 2 bytes long and isn't split.
+			desc:      "TruncateMessageRune",
+			transform: EventTransform{MaxMessageLength: 27},
+			check: func(ev *v1.Event) bool {
+				return ev.Message == "Back-off pulling image \"caf"
+			},
+		},
+		{
+			desc:      "ShortMessage",
+			transform: EventTransform{MaxMessageLength: 100},
+			check: func(ev *v1.Event) bool {
+				return ev.Message == newTransformTestEvent().Message
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Parallel()
+			ev := newTransformTestEvent()
+			tc.transform.Transform(ev)
+			if !tc.check(ev) {
+				t.Fatalf("unexpected transformed event %+v", ev)
+			}
+		})
+	}
+}
+
+func TestTransformingListerWatcher(t *testing.T) {
+	fakeWatcher := watch.NewFake()
+	lw := NewTransformingListerWatcher(&cache.ListWatch{
+		ListFunc: func(metav1.ListOptions) (runtime.Object, error) {
+			return &v1.EventList{Items: []v1.Event{*newTransformTestEvent()}}, nil
+		},
+		WatchFunc: func(metav1.ListOptions) (watch.Interface, error) {
+			return fakeWatcher, nil
+		},
+	}, EventTransform{DropUnusedFields: true}.Transform)
+
+	list, err := lw.List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ev := list.(*v1.EventList).Items[0]; ev.ManagedFields != nil {
+		t.Fatalf("expected listed event to be transformed, got %+v", ev)
+	}
+
+	w, err := lw.Watch(metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	go func() {
+		fakeWatcher.Error(&metav1.Status{Message: "expired"})
+		fakeWatcher.Add(newTransformTestEvent())
+	}()
+	if event := <-w.ResultChan(); event.Type != watch.Error {
+		t.Fatalf("expected watch error to be passed through, got %v", event.Type)
+	}
+	if event := <-w.ResultChan(); event.Object.(*v1.Event).ManagedFields != nil {
+		t.Fatalf("expected watched event to be transformed, got %+v", event.Object)
+	}
+}
+
+func TestCacheSizeHandler(t *testing.T) {
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{Name: "cache_bytes"})
+	handler := NewCacheSizeHandler(gauge)
+
+	small := newTransformTestEvent()
+	large := newTransformTestEvent()
+	large.Message += " after 5 attempts"
+
+	handler.OnAdd(small)
+	handler.OnAdd(small)
+	if got := testutil.ToFloat64(gauge); got != float64(2*small.Size()) {
+		t.Fatalf("expected cache size %d, got %f", 2*small.Size(), got)
+	}
+
+	handler.OnUpdate(small, large)
+	handler.OnDelete(small)
+	handler.OnDelete(cache.DeletedFinalStateUnknown{Key: "default/pod.1", Obj: large})
+	if got := testutil.ToFloat64(gauge); got != 0 {
+		t.Fatalf("expected empty cache size, got %f", got)
+	}
+}